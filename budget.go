@@ -0,0 +1,72 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrorBudget selects which errors count towards ErrorsToTolerate.
+type ErrorBudget int
+
+const (
+	// ErrorsPerRun counts every error since Run was called
+	ErrorsPerRun ErrorBudget = iota
+	// ErrorsPerIteration counts the errors of the current iteration only
+	ErrorsPerIteration
+	// ConsecutiveErrors counts the errors since the last task which succeeded
+	ConsecutiveErrors
+	// ErrorsInWindow counts the errors of the last ErrorBudgetWindow
+	ErrorsInWindow
+)
+
+// DefaultErrorBudgetWindow is the window of the ErrorsInWindow budget when
+// ErrorBudgetWindow is not set.
+const DefaultErrorBudgetWindow = time.Minute
+
+func (b ErrorBudget) String() string {
+	switch b {
+	case ErrorsPerRun:
+		return "per-run"
+	case ErrorsPerIteration:
+		return "per-iteration"
+	case ConsecutiveErrors:
+		return "consecutive"
+	case ErrorsInWindow:
+		return "sliding-window"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseErrorBudget(budget string) (ErrorBudget, error) {
+	switch strings.ToLower(budget) {
+	case "per-run":
+		return ErrorsPerRun, nil
+	case "per-iteration":
+		return ErrorsPerIteration, nil
+	case "consecutive":
+		return ConsecutiveErrors, nil
+	case "sliding-window":
+		return ErrorsInWindow, nil
+	default:
+		return ErrorsPerRun, fmt.Errorf("unknown error budget %q", budget)
+	}
+}
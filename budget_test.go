@@ -0,0 +1,34 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorBudget(t *testing.T) {
+	for _, budget := range []ErrorBudget{ErrorsPerRun, ErrorsPerIteration, ConsecutiveErrors, ErrorsInWindow} {
+		parsed, err := ParseErrorBudget(budget.String())
+		require.Nil(t, err)
+		require.Equal(t, budget, parsed)
+	}
+	_, err := ParseErrorBudget("unlimited")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,126 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Checkpoint is the progress of a run in its current iteration. Tasks are
+// identified by their path, the names of their ancestors and their own name
+// joined with slashes.
+type Checkpoint struct {
+	RunID     string `json:"runId"`
+	Iteration int    `json:"iteration"`
+	// Completed are the tasks which finished all their phases and children
+	// in the current iteration
+	Completed []string `json:"completed"`
+	// Parents are the tasks which finished all their phases in the current
+	// iteration but not their children yet
+	Parents  []ParentProgress `json:"parents,omitempty"`
+	Phases   []PhaseOutcome   `json:"phases"`
+	Finished bool             `json:"finished"`
+	Error    string           `json:"error,omitempty"`
+	Updated  time.Time        `json:"updated"`
+}
+
+// ParentProgress is a task whose children had not all completed. Children
+// are the names of the children it returned, pending until they are
+// Completed.
+type ParentProgress struct {
+	Task     string   `json:"task"`
+	Children []string `json:"children"`
+}
+
+type PhaseOutcome struct {
+	Task  string `json:"task"`
+	Phase Phase  `json:"phase"`
+	Error string `json:"error,omitempty"`
+}
+
+// Checkpointer persists the progress of runs so they can be resumed. Load
+// returns nil without an error when there is no checkpoint for the run.
+type Checkpointer interface {
+	Save(checkpoint *Checkpoint) error
+	Load(runID string) (*Checkpoint, error)
+}
+
+// FileCheckpointer stores every checkpoint as a JSON file in a directory.
+type FileCheckpointer struct {
+	Dir string
+}
+
+func NewFileCheckpointer(dir string) *FileCheckpointer {
+	return &FileCheckpointer{dir}
+}
+
+var unsafeRunID = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (f *FileCheckpointer) path(runID string) string {
+	return filepath.Join(f.Dir, unsafeRunID.ReplaceAllString(runID, "_")+".json")
+}
+
+// Save writes the checkpoint to a temporary file and renames it, so a
+// crash never leaves a partially written checkpoint behind.
+func (f *FileCheckpointer) Save(checkpoint *Checkpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.Dir, ".checkpoint-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(checkpoint.RunID))
+}
+
+func (f *FileCheckpointer) Load(runID string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(f.path(runID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("corrupted checkpoint of run %s: %w", runID, err)
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,71 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package execloop
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execloop-checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	checkpointer := NewFileCheckpointer(dir)
+	checkpoint, err := checkpointer.Load("run/1")
+	require.Nil(t, err)
+	require.Nil(t, checkpoint)
+
+	saved := &Checkpoint{
+		RunID:     "run/1",
+		Iteration: 3,
+		Completed: []string{"vm0", "vm1/disk0"},
+		Parents:   []ParentProgress{{Task: "vm1", Children: []string{"disk0", "disk1"}}},
+		Phases:    []PhaseOutcome{{Task: "vm0", Phase: PhasePost}, {Task: "vm1", Phase: PhasePre, Error: "boom"}},
+	}
+	require.Nil(t, checkpointer.Save(saved))
+	saved.Iteration = 4
+	require.Nil(t, checkpointer.Save(saved))
+
+	checkpoint, err = checkpointer.Load("run/1")
+	require.Nil(t, err)
+	require.Equal(t, saved.RunID, checkpoint.RunID)
+	require.Equal(t, 4, checkpoint.Iteration)
+	require.Equal(t, saved.Completed, checkpoint.Completed)
+	require.Equal(t, saved.Parents, checkpoint.Parents)
+	require.Equal(t, saved.Phases, checkpoint.Phases)
+
+	// Only the checkpoint should be left in the directory
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "run_1.json", files[0].Name())
+}
+
+func TestFileCheckpointerCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execloop-checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	require.Nil(t, ioutil.WriteFile(dir+"/run.json", []byte("{"), 0644))
+	_, err = NewFileCheckpointer(dir).Load("run")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,146 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+	"github.com/kouzant/execloop/metrics"
+)
+
+// config holds the flags of the command. The fields of execloop.Options
+// with a plain type are bound to their flag directly.
+type config struct {
+	options execloop.Options
+
+	logLevel           string
+	logFormat          string
+	errorBudget        string
+	intervalStrategy   string
+	intervalMin        time.Duration
+	intervalMax        time.Duration
+	intervalMultiplier float64
+	metricsAddr        string
+	metrics            *metrics.Metrics
+	trace              bool
+	checkpointDir      string
+	runID              string
+	reconcile          bool
+	dryRun             bool
+	report             string
+}
+
+func newConfig(fs *flag.FlagSet) *config {
+	c := &config{options: execloop.DefaultOptions()}
+	o := &c.options
+	fs.StringVar(&c.logLevel, "log-level", o.LogLevel.String(), "Minimum level of the logs: debug, info, warning or error")
+	fs.StringVar(&c.logFormat, "log-format", "text", "Format of the logs written to stderr: text or json")
+	fs.DurationVar(&o.SleepBetweenRuns, "sleep-between-runs", o.SleepBetweenRuns, "Time to wait between two iterations")
+	fs.IntVar(&o.ErrorsToTolerate, "errors-to-tolerate", o.ErrorsToTolerate, "Number of errors tolerated by the error budget")
+	fs.StringVar(&c.errorBudget, "error-budget", o.ErrorBudget.String(),
+		"Errors counted by the budget: per-run, per-iteration, consecutive or sliding-window")
+	fs.DurationVar(&o.ErrorBudgetWindow, "error-budget-window", o.ErrorBudgetWindow, "Window of the sliding-window error budget, one minute when zero")
+	fs.DurationVar(&o.ExecutionTimeout, "execution-timeout", o.ExecutionTimeout, "Timeout of the whole run")
+	fs.IntVar(&o.MaxConcurrency, "max-concurrency", o.MaxConcurrency, "Number of tasks executed in parallel")
+	fs.IntVar(&o.RetryPolicy.MaxAttempts, "retry-max-attempts", o.RetryPolicy.MaxAttempts, "Attempts of a failing phase")
+	fs.DurationVar(&o.RetryPolicy.InitialDelay, "retry-initial-delay", o.RetryPolicy.InitialDelay, "Delay before the first retry")
+	fs.DurationVar(&o.RetryPolicy.MaxDelay, "retry-max-delay", o.RetryPolicy.MaxDelay, "Maximum delay between retries")
+	fs.Float64Var(&o.RetryPolicy.Multiplier, "retry-multiplier", o.RetryPolicy.Multiplier, "Multiplier of the delay between retries")
+	fs.Float64Var(&o.RetryPolicy.Jitter, "retry-jitter", o.RetryPolicy.Jitter, "Fraction of the delay between retries randomized")
+	fs.StringVar(&c.intervalStrategy, "interval-strategy", "",
+		"Interval between iterations: constant, exponential or decorrelated-jitter, instead of -sleep-between-runs")
+	fs.DurationVar(&c.intervalMin, "interval-min", time.Second, "Minimum interval of the interval strategy")
+	fs.DurationVar(&c.intervalMax, "interval-max", time.Minute, "Maximum interval of the interval strategy")
+	fs.Float64Var(&c.intervalMultiplier, "interval-multiplier", 2, "Multiplier of the exponential interval strategy")
+	fs.StringVar(&c.metricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on, such as :9090")
+	fs.BoolVar(&c.trace, "trace", false, "Print the spans of the run to stderr once it finishes")
+	fs.StringVar(&c.checkpointDir, "checkpoint-dir", "", "Directory to checkpoint the run in")
+	fs.StringVar(&c.runID, "run-id", "", "ID of the run, resuming it from -checkpoint-dir if it was interrupted")
+	fs.BoolVar(&o.RollbackOnFatal, "rollback-on-fatal", o.RollbackOnFatal,
+		"Run the compensate commands of succeeded tasks on a fatal error")
+	fs.IntVar(&o.MaxIdenticalIterations, "max-identical-iterations", o.MaxIdenticalIterations,
+		"Stop after that many successful iterations with the same tasks")
+	fs.IntVar(&o.MaxIterations, "max-iterations", o.MaxIterations, "Maximum number of iterations")
+	fs.IntVar(&o.MaxTaskAttempts, "max-task-attempts", o.MaxTaskAttempts, "Maximum executions of a task")
+	fs.IntVar(&o.MaxTotalTaskExecutions, "max-total-task-executions", o.MaxTotalTaskExecutions,
+		"Maximum executions of all tasks")
+	fs.DurationVar(&o.TaskTimeout, "task-timeout", o.TaskTimeout, "Timeout of the phases of a task together")
+	fs.DurationVar(&o.PreTimeout, "pre-timeout", o.PreTimeout, "Timeout of the pre command of a task")
+	fs.DurationVar(&o.ActionTimeout, "action-timeout", o.ActionTimeout, "Timeout of the command of a task")
+	fs.DurationVar(&o.PostTimeout, "post-timeout", o.PostTimeout, "Timeout of the post command of a task")
+	fs.BoolVar(&o.TimeoutsAreFatal, "timeouts-are-fatal", o.TimeoutsAreFatal, "Stop the run when a task times out")
+	fs.BoolVar(&o.PanicsAreFatal, "panics-are-fatal", o.PanicsAreFatal, "Stop the run when a task panics")
+	fs.BoolVar(&o.WaitForTrigger, "wait-for-trigger", o.WaitForTrigger,
+		"Wait for SIGHUP between iterations instead of polling")
+	fs.DurationVar(&o.SteadyStateInterval, "steady-state-interval", o.SteadyStateInterval,
+		"Time to wait once converged with -reconcile")
+	fs.BoolVar(&c.reconcile, "reconcile", false, "Keep running after the plan converges until interrupted")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Print the tasks of the plan without running them")
+	fs.StringVar(&c.report, "report", "", "File to write the JSON report of the run, or of the dry run, to, - for stdout")
+	return c
+}
+
+// resolve completes the Options with the values of the flags which need
+// parsing.
+func (c *config) resolve(stderr io.Writer) (*execloop.Options, error) {
+	o := c.options
+	level, err := execloop.ParseLevel(c.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	o.LogLevel = level
+	switch c.logFormat {
+	case "text":
+		o.StructuredLogger = execloop.NewTextLogger(stderr)
+	case "json":
+		o.StructuredLogger = execloop.NewJSONLogger(stderr)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", c.logFormat)
+	}
+	if o.ErrorBudget, err = execloop.ParseErrorBudget(c.errorBudget); err != nil {
+		return nil, err
+	}
+	switch c.intervalStrategy {
+	case "":
+	case "constant":
+		o.IntervalStrategy = execloop.ConstantInterval{Interval: c.intervalMin}
+	case "exponential":
+		o.IntervalStrategy = execloop.ExponentialInterval{Min: c.intervalMin, Max: c.intervalMax,
+			Multiplier: c.intervalMultiplier}
+	case "decorrelated-jitter":
+		o.IntervalStrategy = execloop.DecorrelatedJitterInterval{Min: c.intervalMin, Max: c.intervalMax}
+	default:
+		return nil, fmt.Errorf("unknown interval strategy %q", c.intervalStrategy)
+	}
+	if c.metricsAddr != "" {
+		c.metrics = metrics.New()
+		o.Observers = append(o.Observers, c.metrics)
+	}
+	if c.trace {
+		o.Tracer = executor.NewInMemoryTracer()
+	}
+	if c.checkpointDir != "" {
+		o.Checkpointer = execloop.NewFileCheckpointer(c.checkpointDir)
+	}
+	return &o, nil
+}
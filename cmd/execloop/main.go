@@ -0,0 +1,205 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Command execloop runs a plan described in a YAML or JSON file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+)
+
+// Exit codes of the command.
+const (
+	exitSuccess         = 0
+	exitFatal           = 1
+	exitUsage           = 2
+	exitBudgetExhausted = 3
+	exitTimeout         = 4
+	exitNotConverging   = 5
+	exitLimitReached    = 6
+	exitCancelled       = 130
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func exitCode(reason executor.StopReason) int {
+	switch reason {
+	case executor.StopConverged:
+		return exitSuccess
+	case executor.StopBudgetExhausted:
+		return exitBudgetExhausted
+	case executor.StopTimeout:
+		return exitTimeout
+	case executor.StopNotConverging:
+		return exitNotConverging
+	case executor.StopLimitReached:
+		return exitLimitReached
+	case executor.StopCancelled:
+		return exitCancelled
+	default:
+		return exitFatal
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("execloop", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: execloop [flags] plan.yaml\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(stderr, "\nQueueWorkers has no flag, it only applies to Executor.Drain which execloop does not use.\n")
+	}
+	c := newConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	options, err := c.resolve(stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "execloop: %v\n", err)
+		return exitUsage
+	}
+	file, err := loadPlanFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "execloop: %v\n", err)
+		return exitUsage
+	}
+	plan := executor.FromContextPlan(newFilePlan(file))
+	exec := executor.New(options)
+
+	if c.dryRun {
+		preview, err := exec.DryRun(plan)
+		if err != nil {
+			fmt.Fprintf(stderr, "execloop: %v\n", err)
+			return exitCode(executor.ReasonOf(err))
+		}
+		if c.report != "" {
+			err = writeOutput(c.report, stdout, preview.RenderJSON)
+		} else {
+			err = preview.Render(stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "execloop: %v\n", err)
+			return exitFatal
+		}
+		return exitSuccess
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	notified := []os.Signal{os.Interrupt, syscall.SIGTERM}
+	if triggerSignal != nil {
+		notified = append(notified, triggerSignal)
+	}
+	signal.Notify(signals, notified...)
+	defer signal.Stop(signals)
+	go func() {
+		for s := range signals {
+			if s == triggerSignal {
+				exec.Trigger()
+				continue
+			}
+			options.Info("Interrupted", execloop.F("signal", s))
+			cancel()
+		}
+	}()
+
+	if c.metricsAddr != "" {
+		server := &http.Server{Addr: c.metricsAddr, Handler: c.metrics}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				options.Error("Could not serve metrics", execloop.F("error", err))
+			}
+		}()
+		defer server.Close()
+	}
+
+	var report *executor.RunReport
+	switch {
+	case c.reconcile:
+		err = exec.Reconcile(ctx, plan)
+	case c.runID != "":
+		report, err = exec.Resume(ctx, c.runID, plan)
+	default:
+		report, err = exec.RunWithResult(ctx, plan)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "execloop: %v\n", err)
+	}
+	if report != nil && c.report != "" {
+		werr := writeOutput(c.report, stdout, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		})
+		if werr != nil {
+			fmt.Fprintf(stderr, "execloop: %v\n", werr)
+		}
+	}
+	if tracer, ok := options.Tracer.(*executor.InMemoryTracer); ok {
+		printSpans(stderr, tracer.Roots(), 0)
+	}
+	return exitCode(executor.ReasonOf(err))
+}
+
+// writeOutput writes to the file at path, or to stdout when path is -.
+func writeOutput(path string, stdout io.Writer, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printSpans(w io.Writer, spans []*executor.RecordedSpan, depth int) {
+	for _, span := range spans {
+		var attributes []string
+		for key, value := range span.Attributes {
+			attributes = append(attributes, fmt.Sprintf("%s=%v", key, value))
+		}
+		sort.Strings(attributes)
+		fmt.Fprintf(w, "%s%s %s %s\n", strings.Repeat("  ", depth), span.Name, span.Ended.Sub(span.Started),
+			strings.Join(attributes, " "))
+		printSpans(w, span.Children, depth+1)
+	}
+}
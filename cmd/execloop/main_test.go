@@ -0,0 +1,197 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlPlan = `
+tasks:
+  - name: network
+    description: Create the network
+    command: [sh, -c, "echo $NAME > network"]
+    env:
+      NAME: net0
+    check:
+      command: [test, -f, network]
+  - name: vm
+    command: [sh, -c, "cat network"]
+    depends_on: [network]
+    timeout: 1m
+    retry:
+      max_attempts: 2
+      initial_delay: 10ms
+    post:
+      command: [echo, done]
+`
+
+const jsonPlan = `{
+  "tasks": [
+    {
+      "name": "network",
+      "description": "Create the network",
+      "command": ["sh", "-c", "echo $NAME > network"],
+      "env": {"NAME": "net0"},
+      "check": {"command": ["test", "-f", "network"]}
+    },
+    {
+      "name": "vm",
+      "command": ["sh", "-c", "cat network"],
+      "depends_on": ["network"],
+      "timeout": "1m",
+      "retry": {"max_attempts": 2, "initial_delay": "10ms"},
+      "post": {"command": ["echo", "done"]}
+    }
+  ]
+}`
+
+func writePlan(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "execloop")
+	require.Nil(t, err)
+	return dir
+}
+
+func TestLoadPlanFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fromYAML, err := loadPlanFile(writePlan(t, dir, "plan.yaml", yamlPlan))
+	require.Nil(t, err)
+	fromJSON, err := loadPlanFile(writePlan(t, dir, "plan.json", jsonPlan))
+	require.Nil(t, err)
+	require.Equal(t, fromYAML, fromJSON)
+
+	vm := fromYAML.Tasks[1]
+	require.Equal(t, []string{"network"}, vm.DependsOn)
+	require.Equal(t, duration(time.Minute), vm.Timeout)
+	require.Equal(t, duration(10*time.Millisecond), vm.Retry.InitialDelay)
+	require.Equal(t, []string{"NAME=net0"}, fromYAML.Tasks[0].command().Env)
+
+	_, err = loadPlanFile(writePlan(t, dir, "unknown.yaml", "tasks:\n  - name: a\n    command: [true]\n    retries: 3\n"))
+	require.NotNil(t, err)
+	_, err = loadPlanFile(writePlan(t, dir, "twice.yaml", "tasks:\n  - name: a\n    command: [true]\n  - name: a\n    command: [true]\n"))
+	require.Contains(t, err.Error(), "task a appears more than once")
+	_, err = loadPlanFile(writePlan(t, dir, "empty.yaml", "tasks:\n  - name: a\n"))
+	require.Contains(t, err.Error(), "task a has no command")
+	_, err = loadPlanFile(writePlan(t, dir, "unknown-dependency.yaml",
+		"tasks:\n  - name: a\n    command: [true]\n    depends_on: [b]\n"))
+	require.Contains(t, err.Error(), "task a depends on unknown task b")
+	_, err = loadPlanFile(writePlan(t, dir, "cycle.yaml",
+		"tasks:\n  - name: a\n    command: [true]\n    depends_on: [c]\n"+
+			"  - name: b\n    command: [true]\n    depends_on: [a]\n"+
+			"  - name: c\n    command: [true]\n    depends_on: [b]\n"))
+	require.Contains(t, err.Error(), "tasks depend on each other: a -> c -> b -> a")
+	_, err = loadPlanFile(writePlan(t, dir, "self.yaml", "tasks:\n  - name: a\n    command: [true]\n    depends_on: [a]\n"))
+	require.Contains(t, err.Error(), "tasks depend on each other: a -> a")
+}
+
+func TestRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	require.Nil(t, err)
+	require.Nil(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	path := writePlan(t, dir, "plan.yaml", yamlPlan)
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-sleep-between-runs", "10ms", "-report", "-", "-log-level", "error", path}, &stdout, &stderr)
+	require.Equal(t, exitSuccess, code, stderr.String())
+
+	var report executor.RunReport
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Equal(t, executor.StopConverged, report.Reason)
+	require.Len(t, report.Iterations, 1)
+	vm := report.Iterations[0].Tasks[1]
+	require.Equal(t, "vm", vm.Name)
+	require.Equal(t, "net0\n", vm.Phases[1].Stdout)
+	require.Equal(t, "done\n", vm.Phases[2].Stdout)
+
+	// the network exists so only vm has to run again
+	stdout.Reset()
+	require.Equal(t, exitSuccess, run([]string{"-dry-run", path}, &stdout, &stderr))
+	require.Equal(t, "└── vm (after network): sh -c cat network\n", stdout.String())
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fatal := writePlan(t, dir, "fatal.yaml", "tasks:\n  - name: a\n    command: [sh, -c, 'exit 3']\n    fatal_exit_codes: [3]\n")
+	failing := writePlan(t, dir, "failing.yaml", "tasks:\n  - name: a\n    command: [\"false\"]\n")
+	sleepy := writePlan(t, dir, "sleepy.yaml", "tasks:\n  - name: a\n    command: [sleep, '10']\n")
+	cycle := writePlan(t, dir, "cycle.yaml", "tasks:\n  - name: a\n    command: [true]\n    depends_on: [a]\n")
+	flags := []string{"-sleep-between-runs", "1ms", "-log-format", "json"}
+
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{fatal}, exitFatal},
+		{[]string{"-errors-to-tolerate", "2", failing}, exitBudgetExhausted},
+		{[]string{"-errors-to-tolerate", "20", "-max-iterations", "3", failing}, exitLimitReached},
+		{[]string{"-max-task-attempts", "2", failing}, exitLimitReached},
+		{[]string{"-execution-timeout", "100ms", sleepy}, exitTimeout},
+		{[]string{"-unknown-flag", fatal}, exitUsage},
+		{[]string{"-error-budget", "unlimited", fatal}, exitUsage},
+		{[]string{filepath.Join(dir, "missing.yaml")}, exitUsage},
+		{[]string{cycle}, exitUsage},
+		{nil, exitUsage},
+	} {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, test.code, run(append(append([]string(nil), flags...), test.args...), &stdout, &stderr),
+			"%v: %s", test.args, stderr.String())
+	}
+}
+
+func TestResolveFlags(t *testing.T) {
+	fs := flag.NewFlagSet("execloop", flag.ContinueOnError)
+	c := newConfig(fs)
+	require.Nil(t, fs.Parse([]string{"-error-budget", "consecutive", "-interval-strategy", "exponential",
+		"-interval-min", "2s", "-max-concurrency", "4", "-retry-max-attempts", "3", "-checkpoint-dir", "/tmp/x",
+		"-metrics-addr", ":0", "-trace", "-wait-for-trigger", "-steady-state-interval", "1m"}))
+	options, err := c.resolve(ioutil.Discard)
+	require.Nil(t, err)
+	require.Equal(t, execloop.ConsecutiveErrors, options.ErrorBudget)
+	require.Equal(t, execloop.ExponentialInterval{Min: 2 * time.Second, Max: time.Minute, Multiplier: 2},
+		options.IntervalStrategy)
+	require.Equal(t, 4, options.MaxConcurrency)
+	require.Equal(t, 3, options.RetryPolicy.MaxAttempts)
+	require.Equal(t, execloop.NewFileCheckpointer("/tmp/x"), options.Checkpointer)
+	require.Len(t, options.Observers, 1)
+	require.NotNil(t, options.Tracer)
+	require.True(t, options.WaitForTrigger)
+	require.Equal(t, time.Minute, options.SteadyStateInterval)
+	require.Equal(t, 5, options.ErrorsToTolerate)
+}
@@ -0,0 +1,330 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+	"github.com/kouzant/execloop/tasks"
+	"gopkg.in/yaml.v2"
+)
+
+// planFile is the declarative description of a plan, read from YAML or
+// JSON.
+type planFile struct {
+	Tasks []taskSpec `yaml:"tasks" json:"tasks"`
+}
+
+type commandSpec struct {
+	Command []string          `yaml:"command" json:"command"`
+	Env     map[string]string `yaml:"env" json:"env"`
+	Dir     string            `yaml:"dir" json:"dir"`
+	Stdin   string            `yaml:"stdin" json:"stdin"`
+	Timeout duration          `yaml:"timeout" json:"timeout"`
+}
+
+type taskSpec struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	commandSpec `yaml:",inline"`
+	Pre         *commandSpec `yaml:"pre" json:"pre"`
+	Post        *commandSpec `yaml:"post" json:"post"`
+	// Check tells whether the task has to run, it is skipped while the
+	// command succeeds
+	Check              *commandSpec `yaml:"check" json:"check"`
+	Compensate         *commandSpec `yaml:"compensate" json:"compensate"`
+	DependsOn          []string     `yaml:"depends_on" json:"depends_on"`
+	RetryableExitCodes []int        `yaml:"retryable_exit_codes" json:"retryable_exit_codes"`
+	FatalExitCodes     []int        `yaml:"fatal_exit_codes" json:"fatal_exit_codes"`
+	Retry              *retrySpec   `yaml:"retry" json:"retry"`
+	TaskTimeout        duration     `yaml:"task_timeout" json:"task_timeout"`
+}
+
+type retrySpec struct {
+	MaxAttempts  int      `yaml:"max_attempts" json:"max_attempts"`
+	InitialDelay duration `yaml:"initial_delay" json:"initial_delay"`
+	MaxDelay     duration `yaml:"max_delay" json:"max_delay"`
+	Multiplier   float64  `yaml:"multiplier" json:"multiplier"`
+	Jitter       float64  `yaml:"jitter" json:"jitter"`
+}
+
+// duration is a time.Duration written as a string such as "1m30s".
+type duration time.Duration
+
+func (d *duration) set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.set(s)
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.set(s)
+}
+
+// loadPlanFile reads a plan file, as JSON when its extension is .json and
+// as YAML otherwise.
+func loadPlanFile(path string) (*planFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file planFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		err = yaml.UnmarshalStrict(content, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse plan file %s: %w", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid plan file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+func (f *planFile) validate() error {
+	if len(f.Tasks) == 0 {
+		return fmt.Errorf("no tasks")
+	}
+	names := make(map[string]bool, len(f.Tasks))
+	for i, task := range f.Tasks {
+		if task.Name == "" {
+			return fmt.Errorf("task %d has no name", i)
+		}
+		if names[task.Name] {
+			return fmt.Errorf("task %s appears more than once", task.Name)
+		}
+		names[task.Name] = true
+		if len(task.Command) == 0 {
+			return fmt.Errorf("task %s has no command", task.Name)
+		}
+		for _, command := range []*commandSpec{task.Pre, task.Post, task.Check, task.Compensate} {
+			if command != nil && len(command.Command) == 0 {
+				return fmt.Errorf("task %s has an empty command", task.Name)
+			}
+		}
+	}
+	dependencies := make(map[string][]string, len(f.Tasks))
+	for _, task := range f.Tasks {
+		for _, dependency := range task.DependsOn {
+			if !names[dependency] {
+				return fmt.Errorf("task %s depends on unknown task %s", task.Name, dependency)
+			}
+		}
+		dependencies[task.Name] = task.DependsOn
+	}
+	return checkCycles(f.Tasks, dependencies)
+}
+
+// checkCycles fails when tasks depend on each other, naming the tasks of
+// the first cycle found.
+func checkCycles(specs []taskSpec, dependencies map[string][]string) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(specs))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i := range path {
+				if path[i] == name {
+					return fmt.Errorf("tasks depend on each other: %s -> %s", strings.Join(path[i:], " -> "), name)
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, spec := range specs {
+		if err := visit(spec.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commandSpec) command() tasks.Command {
+	command := tasks.Command{
+		Path:    c.Command[0],
+		Args:    c.Command[1:],
+		Dir:     c.Dir,
+		Stdin:   c.Stdin,
+		Timeout: time.Duration(c.Timeout),
+	}
+	for key, value := range c.Env {
+		command.Env = append(command.Env, key+"="+value)
+	}
+	sort.Strings(command.Env)
+	return command
+}
+
+func (c *commandSpec) optionalCommand() *tasks.Command {
+	if c == nil {
+		return nil
+	}
+	command := c.command()
+	return &command
+}
+
+// filePlan returns the tasks of a plan file which have not succeeded yet,
+// or whose check command fails.
+type filePlan struct {
+	mu    sync.Mutex
+	tasks []*fileTask
+	done  map[string]bool
+}
+
+func newFilePlan(file *planFile) *filePlan {
+	plan := &filePlan{done: make(map[string]bool)}
+	for _, spec := range file.Tasks {
+		task := &fileTask{
+			ExecTask: &tasks.ExecTask{
+				TaskName:           spec.Name,
+				Description:        spec.Description,
+				Command:            spec.command(),
+				PreCommand:         spec.Pre.optionalCommand(),
+				PostCommand:        spec.Post.optionalCommand(),
+				Dependencies:       spec.DependsOn,
+				RetryableExitCodes: spec.RetryableExitCodes,
+				FatalExitCodes:     spec.FatalExitCodes,
+				Timeout:            time.Duration(spec.TaskTimeout),
+			},
+			check:      spec.Check.optionalCommand(),
+			compensate: spec.Compensate.optionalCommand(),
+			plan:       plan,
+		}
+		if spec.Retry != nil {
+			task.Retry = execloop.RetryPolicy{
+				MaxAttempts:  spec.Retry.MaxAttempts,
+				InitialDelay: time.Duration(spec.Retry.InitialDelay),
+				MaxDelay:     time.Duration(spec.Retry.MaxDelay),
+				Multiplier:   spec.Retry.Multiplier,
+				Jitter:       spec.Retry.Jitter,
+			}
+		}
+		plan.tasks = append(plan.tasks, task)
+	}
+	return plan
+}
+
+func (p *filePlan) Create(ctx context.Context) ([]executor.Task, error) {
+	var remaining []executor.Task
+	for _, task := range p.tasks {
+		if task.needed(ctx) {
+			remaining = append(remaining, task.executorTask())
+		}
+	}
+	return remaining, ctx.Err()
+}
+
+func (p *filePlan) succeeded(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[name] = true
+}
+
+func (p *filePlan) isDone(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done[name]
+}
+
+type fileTask struct {
+	*tasks.ExecTask
+	check      *tasks.Command
+	compensate *tasks.Command
+	plan       *filePlan
+}
+
+func (t *fileTask) Post(ctx context.Context) error {
+	if err := t.ExecTask.Post(ctx); err != nil {
+		return err
+	}
+	t.plan.succeeded(t.Name())
+	return nil
+}
+
+// needed runs the check command of the task if any, otherwise a task is
+// needed until it has succeeded once.
+func (t *fileTask) needed(ctx context.Context) bool {
+	if t.check == nil {
+		return !t.plan.isDone(t.Name())
+	}
+	check := &tasks.ExecTask{TaskName: t.Name(), Command: *t.check}
+	_, err := check.PerformAction(ctx)
+	return err != nil
+}
+
+func (t *fileTask) executorTask() executor.Task {
+	if t.compensate != nil {
+		return executor.FromContextTask(&compensatingFileTask{t})
+	}
+	return executor.FromContextTask(t)
+}
+
+type compensatingFileTask struct {
+	*fileTask
+}
+
+func (t *compensatingFileTask) Compensate(ctx context.Context, cause error) error {
+	compensate := &tasks.ExecTask{TaskName: t.Name(), Command: *t.compensate}
+	_, err := compensate.PerformAction(ctx)
+	return err
+}
@@ -16,7 +16,11 @@ along with execloop.  If not, see <https://www.gnu.org/licenses/>.
 */
 package executor
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 type Task interface {
 	Pre() error
@@ -29,6 +33,21 @@ type Plan interface {
 	Create() ([]Task, error)
 }
 
+// ContextTask is a Task whose phases observe cancellation of the run.
+// Wrap it with FromContextTask to return it from a Plan.
+type ContextTask interface {
+	Pre(ctx context.Context) error
+	PerformAction(ctx context.Context) ([]Task, error)
+	Post(ctx context.Context) error
+	Name() string
+}
+
+// ContextPlan is a Plan whose Create observes cancellation of the run.
+// Wrap it with FromContextPlan to pass it to the Executor.
+type ContextPlan interface {
+	Create(ctx context.Context) ([]Task, error)
+}
+
 type FatalError struct {
 	msg string
 	err error
@@ -41,3 +60,81 @@ func (e *FatalError) Error() string {
 func (e *FatalError) Unwrap() error {
 	return e.err
 }
+
+// Fatal wraps err in a FatalError which stops the execution of the plan. It
+// returns nil if err is nil.
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FatalError{err.Error(), err}
+}
+
+// Fatalf formats a FatalError. The error of a %w verb, if any, is wrapped.
+func Fatalf(format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	return &FatalError{err.Error(), errors.Unwrap(err)}
+}
+
+func IsFatal(err error) bool {
+	var ferr *FatalError
+	return errors.As(err, &ferr)
+}
+
+// RetryableError marks a transient error. It is retried according to the
+// RetryPolicy and, if it persists, it does not count towards
+// ErrorsToTolerate. The task is executed again in the next iteration. A
+// FatalError anywhere in the chain of the error takes precedence.
+type RetryableError struct {
+	err error
+}
+
+// Retryable returns nil if err is nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{err}
+}
+
+func (e *RetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.err
+}
+
+func IsRetryable(err error) bool {
+	var rerr *RetryableError
+	return errors.As(err, &rerr)
+}
+
+// PermanentError marks an error which will not go away by retrying. It is
+// not retried, it does not count towards ErrorsToTolerate and the task is
+// skipped for the rest of the run. A FatalError anywhere in the chain of the
+// error takes precedence.
+type PermanentError struct {
+	err error
+}
+
+// Permanent returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.err
+}
+
+func IsPermanent(err error) bool {
+	var perr *PermanentError
+	return errors.As(err, &perr)
+}
@@ -0,0 +1,112 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+type BudgetExhaustedError struct {
+	Budget execloop.ErrorBudget
+	Errors int
+	Limit  int
+	Err    error
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	return fmt.Sprintf("Exhausted %s error budget with %d errors, tolerating %d", e.Budget, e.Errors, e.Limit)
+}
+
+func (e *BudgetExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// errorBudget keeps track of the errors counting towards ErrorsToTolerate
+// according to the ErrorBudget of the Options.
+type errorBudget struct {
+	mu     sync.Mutex
+	budget execloop.ErrorBudget
+	limit  int
+	window time.Duration
+	errors []time.Time
+}
+
+func newErrorBudget(options *execloop.Options) *errorBudget {
+	window := options.ErrorBudgetWindow
+	if window <= 0 {
+		window = execloop.DefaultErrorBudgetWindow
+	}
+	return &errorBudget{
+		budget: options.ErrorBudget,
+		limit:  options.ErrorsToTolerate,
+		window: window,
+	}
+}
+
+func (b *errorBudget) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.errors)
+}
+
+func (b *errorBudget) reset() {
+	b.mu.Lock()
+	b.errors = nil
+	b.mu.Unlock()
+}
+
+func (b *errorBudget) newIteration() {
+	if b.budget != execloop.ErrorsPerIteration {
+		return
+	}
+	b.mu.Lock()
+	b.errors = nil
+	b.mu.Unlock()
+}
+
+func (b *errorBudget) success() {
+	if b.budget != execloop.ConsecutiveErrors {
+		return
+	}
+	b.mu.Lock()
+	b.errors = nil
+	b.mu.Unlock()
+}
+
+// record counts an error and returns a BudgetExhaustedError if the budget
+// has been exhausted.
+func (b *errorBudget) record(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.errors = append(b.errors, now)
+	if b.budget == execloop.ErrorsInWindow {
+		expired := 0
+		for expired < len(b.errors) && now.Sub(b.errors[expired]) > b.window {
+			expired++
+		}
+		b.errors = b.errors[expired:]
+	}
+	if len(b.errors) > b.limit {
+		return &BudgetExhaustedError{b.budget, len(b.errors), b.limit, err}
+	}
+	return nil
+}
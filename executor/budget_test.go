@@ -0,0 +1,102 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+func newTasksLog() [][]int {
+	return [][]int{
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+}
+
+func TestErrorBudgetResetsPerRun(t *testing.T) {
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(3)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), &FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 2})
+	require.Nil(t, err)
+	require.Equal(t, 3, report.BudgetErrors)
+	report, err = exec.RunWithResult(context.Background(), &FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 2})
+	require.Nil(t, err)
+	require.Equal(t, 3, report.BudgetErrors)
+}
+
+func TestErrorBudgetPerIteration(t *testing.T) {
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(1).
+		WithErrorBudget(execloop.ErrorsPerIteration)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(&FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 10}))
+}
+
+func TestConsecutiveErrorBudget(t *testing.T) {
+	newTasks := func() []Task {
+		var tasks []Task
+		for _, fail := range []bool{true, true, false, true, true} {
+			tasks = append(tasks, &FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: boolToInt(fail)})
+		}
+		return tasks
+	}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(2).
+		WithErrorBudget(execloop.ConsecutiveErrors)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(&ConcurrentPlan{tasks: newTasks()}))
+
+	opts = opts.WithErrorBudget(execloop.ErrorsPerRun)
+	exec = New(&opts)
+	err := exec.Run(&ConcurrentPlan{tasks: newTasks()})
+	var budgetError *BudgetExhaustedError
+	require.True(t, errors.As(err, &budgetError))
+	require.Equal(t, execloop.ErrorsPerRun, budgetError.Budget)
+	require.Equal(t, 3, budgetError.Errors)
+}
+
+func TestSlidingWindowErrorBudget(t *testing.T) {
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(30 * time.Millisecond).WithErrorsToTolerate(1).
+		WithErrorBudget(execloop.ErrorsInWindow).WithErrorBudgetWindow(20 * time.Millisecond)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(&FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 5}))
+
+	opts = opts.WithErrorBudgetWindow(time.Minute)
+	exec = New(&opts)
+	err := exec.Run(&FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 5})
+	var budgetError *BudgetExhaustedError
+	require.True(t, errors.As(err, &budgetError))
+	require.Equal(t, execloop.ErrorsInWindow, budgetError.Budget)
+
+	// a zero window falls back to DefaultErrorBudgetWindow
+	opts = opts.WithErrorBudgetWindow(0)
+	exec = New(&opts)
+	err = exec.Run(&FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 5})
+	require.True(t, errors.As(err, &budgetError))
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,229 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+func newRunID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ResumableTask recreates the children of a task, so that a resumed run
+// executes the children which had not completed without running the phases
+// of the task again. Tasks which don't implement it are executed again.
+type ResumableTask interface {
+	Children() ([]Task, error)
+}
+
+// Resume runs the plan like RunWithResult under the given run ID. If the
+// Checkpointer of the Options has a checkpoint of an unfinished run with
+// this ID, the run continues from the iteration it was interrupted in,
+// skipping the tasks which had already completed in it.
+func (e *Executor) Resume(ctx context.Context, runID string, plan Plan) (*RunReport, error) {
+	e.options.Debug("Resuming run", execloop.F("run", runID))
+	x, ctx, err := e.start(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer e.done(x)
+	if err := x.restore(); err != nil {
+		return x.finish(err), err
+	}
+	return e.runWithTimeout(ctx, x, plan)
+}
+
+func (x *execution) restore() error {
+	if x.options.Checkpointer == nil {
+		return nil
+	}
+	checkpoint, err := x.options.Checkpointer.Load(x.id)
+	if err != nil || checkpoint == nil {
+		return err
+	}
+	if checkpoint.Finished {
+		x.options.Info("Previous run has finished, starting over", execloop.F("run", x.id))
+		return nil
+	}
+	x.options.Info("Resuming run from checkpoint", execloop.F("run", x.id),
+		execloop.F("iteration", checkpoint.Iteration), execloop.F("completed", len(checkpoint.Completed)),
+		execloop.F("parents", len(checkpoint.Parents)))
+	x.firstIteration = checkpoint.Iteration
+	x.resumed = make(map[string]bool, len(checkpoint.Completed))
+	for _, task := range checkpoint.Completed {
+		x.resumed[task] = true
+	}
+	x.resumedParents = make(map[string][]string, len(checkpoint.Parents))
+	for _, parent := range checkpoint.Parents {
+		x.resumedParents[parent.Task] = parent.Children
+	}
+	x.checkpoint = checkpoint
+	return nil
+}
+
+// taskPath identifies a task in the checkpoints by the names of its
+// ancestors and its own.
+func taskPath(parent *TaskReport, name string) string {
+	if parent == nil {
+		return name
+	}
+	return parent.path + "/" + name
+}
+
+// withoutResumed drops the tasks which completed in the iteration the run
+// was resumed from.
+func (x *execution) withoutResumed(tasks []Task, parent *TaskReport) []Task {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.resumed) == 0 {
+		return tasks
+	}
+	var remaining []Task
+	for _, task := range tasks {
+		if x.resumed[taskPath(parent, task.Name())] {
+			x.options.Debug("Skipping task completed before resuming", execloop.F("task", task.Name()))
+			continue
+		}
+		remaining = append(remaining, task)
+	}
+	return remaining
+}
+
+// resumedChildren returns the pending children of a task whose phases
+// completed in the iteration the run was resumed from, and whether the task
+// was resumed.
+func (x *execution) resumedChildren(task Task, report *TaskReport) ([]Task, bool) {
+	x.mu.Lock()
+	pending, ok := x.resumedParents[report.path]
+	x.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	t, ok := implementation(task).(ResumableTask)
+	if !ok {
+		x.options.Debug("Executing task again, its children can't be recreated", x.taskFields(task)...)
+		return nil, false
+	}
+	children, err := t.Children()
+	if err != nil {
+		x.options.Warning("Executing task again, its children could not be recreated",
+			x.taskFields(task, execloop.F("error", err))...)
+		return nil, false
+	}
+	names := make(map[string]bool, len(pending))
+	for _, name := range pending {
+		names[name] = true
+	}
+	var remaining []Task
+	for _, child := range children {
+		if names[child.Name()] {
+			remaining = append(remaining, child)
+		}
+	}
+	x.options.Info("Resuming children of task", x.taskFields(task, execloop.F("children", len(remaining)))...)
+	return remaining, true
+}
+
+func (x *execution) checkpointIteration(iteration int) {
+	x.mu.Lock()
+	if iteration != x.firstIteration {
+		x.resumed = nil
+		x.resumedParents = nil
+	}
+	x.mu.Unlock()
+	x.saveCheckpoint(func(checkpoint *execloop.Checkpoint) {
+		if checkpoint.Iteration != iteration {
+			checkpoint.Iteration = iteration
+			checkpoint.Completed = nil
+			checkpoint.Parents = nil
+			checkpoint.Phases = nil
+		}
+	})
+}
+
+func (x *execution) checkpointPhase(report *TaskReport, phase execloop.Phase, err error) {
+	x.saveCheckpoint(func(checkpoint *execloop.Checkpoint) {
+		outcome := execloop.PhaseOutcome{Task: report.path, Phase: phase}
+		if err != nil {
+			outcome.Error = err.Error()
+		}
+		checkpoint.Phases = append(checkpoint.Phases, outcome)
+	})
+}
+
+// checkpointParent records a task whose phases completed with the children
+// it returned, which are pending until they complete.
+func (x *execution) checkpointParent(report *TaskReport, children []Task) {
+	x.saveCheckpoint(func(checkpoint *execloop.Checkpoint) {
+		checkpoint.Parents = append(checkpoint.Parents,
+			execloop.ParentProgress{Task: report.path, Children: taskNames(children)})
+	})
+}
+
+func (x *execution) checkpointTask(report *TaskReport) {
+	x.saveCheckpoint(func(checkpoint *execloop.Checkpoint) {
+		checkpoint.Completed = append(checkpoint.Completed, report.path)
+		for i, parent := range checkpoint.Parents {
+			if parent.Task == report.path {
+				checkpoint.Parents = append(checkpoint.Parents[:i], checkpoint.Parents[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// checkpointFinished marks the run as finished unless it was interrupted by
+// its context, in which case it can still be resumed.
+func (x *execution) checkpointFinished(err error) {
+	if reason := ReasonOf(err); reason == StopTimeout || reason == StopCancelled {
+		return
+	}
+	x.saveCheckpoint(func(checkpoint *execloop.Checkpoint) {
+		checkpoint.Finished = true
+		if err != nil {
+			checkpoint.Error = err.Error()
+		}
+	})
+}
+
+func (x *execution) saveCheckpoint(update func(*execloop.Checkpoint)) {
+	if x.options.Checkpointer == nil {
+		return
+	}
+	x.checkpointMu.Lock()
+	defer x.checkpointMu.Unlock()
+	if x.checkpoint == nil {
+		x.checkpoint = &execloop.Checkpoint{RunID: x.id}
+	}
+	update(x.checkpoint)
+	x.checkpoint.Updated = time.Now()
+	checkpoint := *x.checkpoint
+	checkpoint.Completed = append([]string(nil), x.checkpoint.Completed...)
+	checkpoint.Parents = append([]execloop.ParentProgress(nil), x.checkpoint.Parents...)
+	checkpoint.Phases = append([]execloop.PhaseOutcome(nil), x.checkpoint.Phases...)
+	if err := x.options.Checkpointer.Save(&checkpoint); err != nil {
+		x.options.Warning("Could not save checkpoint", execloop.F("run", x.id), execloop.F("error", err))
+	}
+}
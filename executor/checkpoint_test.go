@@ -0,0 +1,181 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type CrashingTask struct {
+	DummyTask
+	executions map[string]int
+	crash      func()
+}
+
+func (c *CrashingTask) PerformAction() ([]Task, error) {
+	c.executions[c.taskName]++
+	if c.crash != nil {
+		c.crash()
+		c.crash = nil
+	}
+	return nil, nil
+}
+
+type ResumablePlan struct {
+	executions map[string]int
+	crash      func()
+	created    bool
+}
+
+func (p *ResumablePlan) Create() ([]Task, error) {
+	if p.created {
+		return nil, nil
+	}
+	p.created = true
+	return []Task{
+		&CrashingTask{DummyTask: DummyTask{taskName: "vm0"}, executions: p.executions},
+		&CrashingTask{DummyTask: DummyTask{taskName: "vm1"}, executions: p.executions, crash: p.crash},
+		&CrashingTask{DummyTask: DummyTask{taskName: "vm2"}, executions: p.executions},
+	}, nil
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execloop-checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	checkpointer := execloop.NewFileCheckpointer(dir)
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithCheckpointer(checkpointer)
+	executions := make(map[string]int)
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err := New(&opts).Resume(ctx, "run-1", &ResumablePlan{executions: executions, crash: cancel})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, "run-1", report.ID)
+
+	checkpoint, err := checkpointer.Load("run-1")
+	require.Nil(t, err)
+	require.False(t, checkpoint.Finished)
+	require.Equal(t, 1, checkpoint.Iteration)
+	require.Equal(t, []string{"vm0"}, checkpoint.Completed)
+	require.Contains(t, checkpoint.Phases, execloop.PhaseOutcome{Task: "vm0", Phase: execloop.PhasePost})
+	require.Contains(t, checkpoint.Phases, execloop.PhaseOutcome{Task: "vm1", Phase: execloop.PhasePre})
+
+	// A new executor, e.g. after a restart, skips vm0
+	report, err = New(&opts).Resume(context.Background(), "run-1", &ResumablePlan{executions: executions})
+	require.Nil(t, err)
+	require.Equal(t, map[string]int{"vm0": 1, "vm1": 2, "vm2": 1}, executions)
+	require.Equal(t, 1, report.Iterations[0].Number)
+	require.Len(t, report.Iterations[0].Tasks, 2)
+
+	checkpoint, err = checkpointer.Load("run-1")
+	require.Nil(t, err)
+	require.True(t, checkpoint.Finished)
+
+	// Resuming a finished run starts over
+	report, err = New(&opts).Resume(context.Background(), "run-1", &ResumablePlan{executions: executions})
+	require.Nil(t, err)
+	require.Equal(t, 2, executions["vm0"])
+}
+
+// ResumableParentTask returns children, and recreates them when its run is
+// resumed.
+type ResumableParentTask struct {
+	CrashingTask
+	children func() []Task
+}
+
+func (p *ResumableParentTask) PerformAction() ([]Task, error) {
+	p.CrashingTask.PerformAction()
+	return p.children(), nil
+}
+
+func (p *ResumableParentTask) Children() ([]Task, error) {
+	return p.children(), nil
+}
+
+type ResumableParentPlan struct {
+	executions map[string]int
+	crash      func()
+	created    bool
+}
+
+func (p *ResumableParentPlan) Create() ([]Task, error) {
+	if p.created {
+		return nil, nil
+	}
+	p.created = true
+	crash := p.crash
+	children := func() []Task {
+		disks := []Task{
+			&CrashingTask{DummyTask: DummyTask{taskName: "disk0"}, executions: p.executions},
+			&CrashingTask{DummyTask: DummyTask{taskName: "disk1"}, executions: p.executions, crash: crash},
+			&CrashingTask{DummyTask: DummyTask{taskName: "disk2"}, executions: p.executions},
+		}
+		crash = nil
+		return disks
+	}
+	return []Task{
+		&ResumableParentTask{CrashingTask: CrashingTask{DummyTask: DummyTask{taskName: "vm0"}, executions: p.executions},
+			children: children},
+		&CrashingTask{DummyTask: DummyTask{taskName: "disk0"}, executions: p.executions},
+	}, nil
+}
+
+func TestResumeChildrenFromCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execloop-checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	checkpointer := execloop.NewFileCheckpointer(dir)
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithCheckpointer(checkpointer)
+	executions := make(map[string]int)
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = New(&opts).Resume(ctx, "run-1", &ResumableParentPlan{executions: executions, crash: cancel})
+	require.Equal(t, context.Canceled, err)
+
+	checkpoint, err := checkpointer.Load("run-1")
+	require.Nil(t, err)
+	require.Equal(t, []string{"vm0/disk0"}, checkpoint.Completed)
+	require.Equal(t, []execloop.ParentProgress{{Task: "vm0", Children: []string{"disk0", "disk1", "disk2"}}},
+		checkpoint.Parents)
+	require.Contains(t, checkpoint.Phases, execloop.PhaseOutcome{Task: "vm0/disk1", Phase: execloop.PhasePre})
+
+	// vm0 is not executed again and only its pending children run, while
+	// the task disk0 of the plan is not mistaken for the child of vm0
+	report, err := New(&opts).Resume(context.Background(), "run-1", &ResumableParentPlan{executions: executions})
+	require.Nil(t, err)
+	require.Equal(t, map[string]int{"vm0": 1, "disk0": 2, "disk1": 2, "disk2": 1}, executions)
+	tasks := report.Iterations[0].Tasks
+	require.Len(t, tasks, 2)
+	require.Equal(t, "vm0", tasks[0].Name)
+	require.Len(t, tasks[0].Phases, 1)
+	require.Equal(t, execloop.PhaseChildren, tasks[0].Phases[0].Phase)
+	require.Len(t, tasks[0].Children, 2)
+
+	checkpoint, err = checkpointer.Load("run-1")
+	require.Nil(t, err)
+	require.True(t, checkpoint.Finished)
+	require.Empty(t, checkpoint.Parents)
+	require.Contains(t, checkpoint.Completed, "vm0")
+}
@@ -0,0 +1,114 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// CompensatingTask is a Task which can undo its side effects. When
+// RollbackOnFatal is set and an iteration fails with a FatalError, the
+// tasks which succeeded in that iteration are compensated in reverse order.
+type CompensatingTask interface {
+	Compensate(ctx context.Context, cause error) error
+}
+
+type CompensationResult struct {
+	Task string
+	Err  error
+}
+
+// CompensationError is returned when the tasks of an iteration were rolled
+// back. It wraps the error which caused the rollback.
+type CompensationError struct {
+	Err     error
+	Results []CompensationResult
+}
+
+func (e *CompensationError) Error() string {
+	var failed []string
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", result.Task, result.Err))
+		}
+	}
+	msg := fmt.Sprintf("%s. Compensated %d tasks", e.Err, len(e.Results))
+	if len(failed) > 0 {
+		msg += fmt.Sprintf(", %d failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return msg
+}
+
+func (e *CompensationError) Unwrap() error {
+	return e.Err
+}
+
+type succeededTask struct {
+	task   Task
+	report *TaskReport
+}
+
+func (x *execution) taskSucceeded(task Task, report *TaskReport) {
+	if !x.options.RollbackOnFatal {
+		return
+	}
+	if _, ok := implementation(task).(CompensatingTask); !ok {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.succeeded = append(x.succeeded, succeededTask{task, report})
+}
+
+// compensate rolls back the tasks which succeeded in the current iteration
+// in the reverse order they completed.
+func (x *execution) compensate(ctx context.Context, cause error) error {
+	x.mu.Lock()
+	succeeded := x.succeeded
+	x.succeeded = nil
+	x.mu.Unlock()
+
+	x.setState(RunCompensating)
+	x.options.Warning("Compensating tasks", execloop.F("tasks", len(succeeded)), execloop.F("error", cause))
+	var results []CompensationResult
+	for i := len(succeeded) - 1; i >= 0; i-- {
+		task, report := succeeded[i].task, succeeded[i].report
+		compensating := implementation(task).(CompensatingTask)
+		x.options.Info("Compensating task", x.taskFields(task)...)
+		x.options.Notify(func(o execloop.Observer) {
+			o.OnTaskPhaseStart(task.Name(), execloop.PhaseCompensate)
+		})
+		spanCtx, span := x.startSpan(ctx, string(execloop.PhaseCompensate), execloop.Attr(AttributeTask, task.Name()),
+			execloop.Attr(AttributePhase, execloop.PhaseCompensate))
+		started := time.Now()
+		err := protect(task.Name(), execloop.PhaseCompensate, func() error {
+			return compensating.Compensate(spanCtx, cause)
+		})
+		x.endPhase(task, report, execloop.PhaseCompensate, started, 1, err)
+		endSpan(span, err)
+		if err != nil {
+			x.options.Error("Could not compensate task", x.taskFields(task, execloop.F("error", err))...)
+		}
+		results = append(results, CompensationResult{task.Name(), err})
+	}
+	return &CompensationError{cause, results}
+}
@@ -0,0 +1,84 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type SagaTask struct {
+	DummyTask
+	err           error
+	compensateErr error
+	compensated   *[]string
+	cause         error
+}
+
+func (s *SagaTask) PerformAction() ([]Task, error) {
+	return nil, s.err
+}
+
+func (s *SagaTask) Compensate(ctx context.Context, cause error) error {
+	*s.compensated = append(*s.compensated, s.taskName)
+	s.cause = cause
+	return s.compensateErr
+}
+
+func TestCompensateOnFatal(t *testing.T) {
+	var compensated []string
+	vm0 := &SagaTask{DummyTask: DummyTask{taskName: "vm0"}, compensated: &compensated}
+	vm1 := &SagaTask{DummyTask: DummyTask{taskName: "vm1"}, compensated: &compensated,
+		compensateErr: errors.New("vm1 is gone")}
+	lb := &SagaTask{DummyTask: DummyTask{taskName: "lb"}, compensated: &compensated, err: Fatalf("no quota")}
+	plan := &ConcurrentPlan{tasks: []Task{vm0, &DummyTask{taskName: "dns"}, vm1, lb}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithRollbackOnFatal(true)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+
+	require.True(t, IsFatal(err))
+	var compensationError *CompensationError
+	require.True(t, errors.As(err, &compensationError))
+	require.Equal(t, []CompensationResult{{"vm1", vm1.compensateErr}, {"vm0", nil}}, compensationError.Results)
+	require.Equal(t, "FatalError: no quota. Compensated 2 tasks, 1 failed: vm1: vm1 is gone", err.Error())
+	require.Equal(t, []string{"vm1", "vm0"}, compensated)
+	require.True(t, IsFatal(vm0.cause))
+	require.Empty(t, lb.cause)
+
+	require.Equal(t, StopFatal, report.Reason)
+	phases := report.Iterations[0].Tasks[0].Phases
+	require.Equal(t, execloop.PhaseCompensate, phases[len(phases)-1].Phase)
+}
+
+func TestNoCompensationByDefault(t *testing.T) {
+	var compensated []string
+	vm0 := &SagaTask{DummyTask: DummyTask{taskName: "vm0"}, compensated: &compensated}
+	lb := &SagaTask{DummyTask: DummyTask{taskName: "lb"}, compensated: &compensated, err: Fatalf("no quota")}
+	plan := &ConcurrentPlan{tasks: []Task{vm0, lb}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond)
+	exec := New(&opts)
+	err := exec.Run(plan)
+	require.True(t, IsFatal(err))
+	var compensationError *CompensationError
+	require.False(t, errors.As(err, &compensationError))
+	require.Empty(t, compensated)
+}
@@ -0,0 +1,106 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"time"
+)
+
+type contextTask struct {
+	task ContextTask
+}
+
+func FromContextTask(task ContextTask) Task {
+	return &contextTask{task}
+}
+
+func (t *contextTask) Pre() error {
+	return t.task.Pre(context.Background())
+}
+
+func (t *contextTask) PerformAction() ([]Task, error) {
+	return t.task.PerformAction(context.Background())
+}
+
+func (t *contextTask) Post() error {
+	return t.task.Post(context.Background())
+}
+
+func (t *contextTask) Name() string {
+	return t.task.Name()
+}
+
+type contextPlan struct {
+	plan ContextPlan
+}
+
+func FromContextPlan(plan ContextPlan) Plan {
+	return &contextPlan{plan}
+}
+
+func (p *contextPlan) Create() ([]Task, error) {
+	return p.plan.Create(context.Background())
+}
+
+// implementation returns the value provided by the user for a task, so that
+// optional interfaces are looked up on it rather than on the wrapper.
+func implementation(task Task) interface{} {
+	if t, ok := task.(*contextTask); ok {
+		return t.task
+	}
+	return task
+}
+
+func create(ctx context.Context, plan Plan) ([]Task, error) {
+	if p, ok := plan.(*contextPlan); ok {
+		return p.plan.Create(ctx)
+	}
+	return plan.Create()
+}
+
+func pre(ctx context.Context, task Task) error {
+	if t, ok := task.(*contextTask); ok {
+		return t.task.Pre(ctx)
+	}
+	return task.Pre()
+}
+
+func performAction(ctx context.Context, task Task) ([]Task, error) {
+	if t, ok := task.(*contextTask); ok {
+		return t.task.PerformAction(ctx)
+	}
+	return task.PerformAction()
+}
+
+func post(ctx context.Context, task Task) error {
+	if t, ok := task.(*contextTask); ok {
+		return t.task.Post(ctx)
+	}
+	return task.Post()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kouzant/execloop"
+)
+
+// FingerprintedTask refines the identity of a task when detecting plans
+// which do not converge. Two iterations are identical when their tasks have
+// the same names and fingerprints.
+type FingerprintedTask interface {
+	Fingerprint() string
+}
+
+type NotConvergingError struct {
+	// Iterations is the number of iterations executed
+	Iterations int
+	// Identical is the number of consecutive identical iterations, zero
+	// when MaxIterations was reached
+	Identical int
+	Tasks     []string
+	// Err is the LimitError of MaxIterations
+	Err error
+}
+
+func (e *NotConvergingError) Error() string {
+	if e.Identical > 0 {
+		return fmt.Sprintf("Plan is not converging, it returned the same tasks after %d successful iterations: %s",
+			e.Identical, strings.Join(e.Tasks, ", "))
+	}
+	return fmt.Sprintf("Plan did not converge after %d iterations: %s", e.Iterations, e.Err)
+}
+
+func (e *NotConvergingError) Unwrap() error {
+	return e.Err
+}
+
+// convergence tracks the consecutive successful iterations with the same
+// tasks.
+type convergence struct {
+	fingerprint string
+	identical   int
+}
+
+func fingerprintOf(tasks []Task) string {
+	entries := make([]string, len(tasks))
+	for i, task := range tasks {
+		entries[i] = task.Name()
+		if t, ok := implementation(task).(FingerprintedTask); ok {
+			entries[i] += "\x00" + t.Fingerprint()
+		}
+	}
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkConvergence fails when the plan has exceeded MaxIterations or has
+// returned the same tasks for MaxIdenticalIterations.
+func (x *execution) checkConvergence(iteration int, tasks []Task, fingerprint string) error {
+	if max, count := x.options.MaxIterations, iteration-x.cycleStart; max > 0 && count > max {
+		return &NotConvergingError{Iterations: count - 1, Tasks: taskNames(tasks),
+			Err: &LimitError{Limit: LimitIterations, Count: count - 1, Max: max}}
+	}
+	max := x.options.MaxIdenticalIterations
+	if max > 0 && fingerprint == x.convergence.fingerprint && x.convergence.identical >= max {
+		return &NotConvergingError{Iterations: iteration - 1, Identical: x.convergence.identical, Tasks: taskNames(tasks)}
+	}
+	return nil
+}
+
+// progressed tells whether the plan returned different tasks than in the
+// previous iteration.
+func (x *execution) progressed(fingerprint string) bool {
+	progressed := fingerprint != x.fingerprint
+	x.fingerprint = fingerprint
+	return progressed
+}
+
+func (x *execution) endConvergence(fingerprint string, clean bool) {
+	switch {
+	case !clean:
+		x.convergence = convergence{}
+	case fingerprint == x.convergence.fingerprint:
+		x.convergence.identical++
+	default:
+		x.convergence = convergence{fingerprint, 1}
+	}
+	x.options.Debug("Iteration fingerprint", execloop.F("fingerprint", fingerprint),
+		execloop.F("identical", x.convergence.identical))
+}
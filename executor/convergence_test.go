@@ -0,0 +1,103 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type StuckTask struct {
+	DummyTask
+	fingerprint string
+}
+
+func (s *StuckTask) Fingerprint() string {
+	return s.fingerprint
+}
+
+// StuckPlan returns the same task in every iteration, optionally with a
+// fingerprint changing in every iteration.
+type StuckPlan struct {
+	iterations  int
+	progressing bool
+}
+
+func (p *StuckPlan) Create() ([]Task, error) {
+	p.iterations++
+	task := &StuckTask{DummyTask: DummyTask{taskName: "stuck"}}
+	if p.progressing {
+		task.fingerprint = fmt.Sprint(p.iterations)
+	}
+	return []Task{task}, nil
+}
+
+func TestNotConvergingIdenticalIterations(t *testing.T) {
+	plan := &StuckPlan{}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxIdenticalIterations(3)
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	var convergenceError *NotConvergingError
+	require.True(t, errors.As(err, &convergenceError))
+	require.Equal(t, 3, convergenceError.Identical)
+	require.Equal(t, 3, convergenceError.Iterations)
+	require.Equal(t, []string{"stuck"}, convergenceError.Tasks)
+	require.Equal(t, 4, plan.iterations)
+	require.Equal(t, StopNotConverging, report.Reason)
+	require.Len(t, report.Iterations, 3)
+}
+
+func TestNotConvergingMaxIterations(t *testing.T) {
+	plan := &StuckPlan{progressing: true}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxIdenticalIterations(3).WithMaxIterations(5)
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	var convergenceError *NotConvergingError
+	require.True(t, errors.As(err, &convergenceError))
+	require.Equal(t, 0, convergenceError.Identical)
+	require.Equal(t, 5, convergenceError.Iterations)
+	var limitError *LimitError
+	require.True(t, errors.As(err, &limitError))
+	require.Equal(t, LimitIterations, limitError.Limit)
+	require.Equal(t, "Plan did not converge after 5 iterations: Reached MaxIterations limit, 5 out of 5", err.Error())
+	require.Equal(t, StopLimitReached, report.Reason)
+}
+
+func TestFingerprintOf(t *testing.T) {
+	a := &DummyTask{taskName: "a"}
+	b := &DummyTask{taskName: "b"}
+	require.Equal(t, fingerprintOf([]Task{a, b}), fingerprintOf([]Task{b, a}))
+	require.NotEqual(t, fingerprintOf([]Task{a}), fingerprintOf([]Task{a, b}))
+	require.NotEqual(t,
+		fingerprintOf([]Task{&StuckTask{DummyTask: *a, fingerprint: "1"}}),
+		fingerprintOf([]Task{&StuckTask{DummyTask: *a, fingerprint: "2"}}))
+}
+
+func TestConvergenceResetsOnErrors(t *testing.T) {
+	x := &execution{options: &execloop.Options{MaxIdenticalIterations: 2}}
+	tasks := []Task{&DummyTask{taskName: "a"}}
+	fingerprint := fingerprintOf(tasks)
+	x.endConvergence(fingerprint, true)
+	x.endConvergence(fingerprint, false)
+	x.endConvergence(fingerprint, true)
+	require.Nil(t, x.checkConvergence(4, tasks, fingerprint))
+	x.endConvergence(fingerprint, true)
+	require.NotNil(t, x.checkConvergence(5, tasks, fingerprint))
+}
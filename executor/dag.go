@@ -0,0 +1,258 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kouzant/execloop"
+)
+
+// DependentTask is a Task which should only be executed after the tasks
+// with the given names, returned in the same set, have succeeded. A set is
+// executed as a graph only when a task has at least one dependency, so task
+// types which always implement DependentTask keep the list order otherwise.
+type DependentTask interface {
+	DependsOn() []string
+}
+
+type DependencyCycleError struct {
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("Dependency cycle between tasks: %s", strings.Join(e.Cycle, " -> "))
+}
+
+type taskGraph struct {
+	tasks      []Task
+	index      map[string]int
+	dependents [][]int
+	inDegree   []int
+}
+
+func hasDependencies(tasks []Task) bool {
+	for _, task := range tasks {
+		if len(dependenciesOf(task)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func dependenciesOf(task Task) []string {
+	if t, ok := implementation(task).(DependentTask); ok {
+		return t.DependsOn()
+	}
+	return nil
+}
+
+func newTaskGraph(tasks []Task) (*taskGraph, error) {
+	index := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if _, ok := index[task.Name()]; ok {
+			return nil, &FatalError{fmt.Sprintf("Task %s appears more than once in the dependency graph", task.Name()), nil}
+		}
+		index[task.Name()] = i
+	}
+
+	g := &taskGraph{
+		tasks:      tasks,
+		index:      index,
+		dependents: make([][]int, len(tasks)),
+		inDegree:   make([]int, len(tasks)),
+	}
+	for i, task := range tasks {
+		for _, dependency := range dependenciesOf(task) {
+			j, ok := index[dependency]
+			if !ok {
+				// Tasks which are not part of this set are considered done,
+				// unless they failed permanently
+				continue
+			}
+			g.dependents[j] = append(g.dependents[j], i)
+			g.inDegree[i]++
+		}
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, &FatalError{"Invalid task dependency graph", &DependencyCycleError{cycle}}
+	}
+	return g, nil
+}
+
+func (g *taskGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.tasks))
+	var stack []int
+	var visit func(int) []string
+	visit = func(i int) []string {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range g.dependents[i] {
+			if state[d] == visiting {
+				var cycle []string
+				for k := len(stack) - 1; k >= 0; k-- {
+					cycle = append([]string{g.tasks[stack[k]].Name()}, cycle...)
+					if stack[k] == d {
+						break
+					}
+				}
+				return append(cycle, g.tasks[d].Name())
+			}
+			if state[d] == unvisited {
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range g.tasks {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// blockDependents marks every task which transitively depends on the failed
+// task as blocked so it is not executed in this iteration.
+func (g *taskGraph) blockDependents(failed int, blocked []bool) []int {
+	var newlyBlocked []int
+	queue := []int{failed}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, d := range g.dependents[i] {
+			if !blocked[d] {
+				blocked[d] = true
+				newlyBlocked = append(newlyBlocked, d)
+				queue = append(queue, d)
+			}
+		}
+	}
+	return newlyBlocked
+}
+
+// skippedDependency returns a dependency of the task which is not part of
+// the graph because it failed permanently, if any.
+func (x *execution) skippedDependency(graph *taskGraph, task Task) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, dependency := range dependenciesOf(task) {
+		if _, ok := graph.index[dependency]; !ok && x.skipped[dependency] {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// executeGraph runs the tasks in topological order, running at most
+// MaxConcurrency ready tasks at the same time.
+func (x *execution) executeGraph(ctx context.Context, tasks []Task, parent *TaskReport) (bool, error) {
+	graph, err := newTaskGraph(tasks)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		ok    bool
+		err   error
+	}
+	results := make(chan result)
+	inDegree := append([]int(nil), graph.inDegree...)
+	blocked := make([]bool, len(tasks))
+	succeeded := true
+	block := func(failed int) {
+		for _, b := range graph.blockDependents(failed, blocked) {
+			x.options.Warning("Task is blocked by failed dependency",
+				x.taskFields(tasks[b], execloop.F("dependency", tasks[failed].Name()))...)
+			x.blockTask(tasks[b], parent)
+		}
+	}
+	for i, task := range tasks {
+		if dependency := x.skippedDependency(graph, task); dependency != "" && !blocked[i] {
+			succeeded = false
+			blocked[i] = true
+			x.options.Warning("Task is blocked by permanently failed dependency",
+				x.taskFields(task, execloop.F("dependency", dependency))...)
+			x.blockTask(task, parent)
+			block(i)
+		}
+	}
+	var ready []int
+	for i := range tasks {
+		if inDegree[i] == 0 && !blocked[i] {
+			ready = append(ready, i)
+		}
+	}
+
+	limit := x.options.MaxConcurrency
+	if limit < 1 {
+		limit = 1
+	}
+	var ferr error
+	running := 0
+	for {
+		for ferr == nil && len(ready) > 0 && running < limit {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+			go func(i int) {
+				ok, err := x.executeTask(ctx, tasks[i], parent)
+				results <- result{i, ok, err}
+			}(i)
+		}
+		if running == 0 {
+			return succeeded, ferr
+		}
+
+		r := <-results
+		running--
+		if r.err != nil {
+			if ferr == nil {
+				ferr = r.err
+				cancel()
+			}
+			continue
+		}
+		if !r.ok {
+			succeeded = false
+			block(r.index)
+			continue
+		}
+		for _, d := range graph.dependents[r.index] {
+			inDegree[d]--
+			if inDegree[d] == 0 && !blocked[d] {
+				ready = append(ready, d)
+			}
+		}
+	}
+}
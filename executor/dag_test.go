@@ -0,0 +1,211 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type GraphTask struct {
+	name      string
+	dependsOn []string
+	fail      bool
+	permanent bool
+	sleep     time.Duration
+	mu        *sync.Mutex
+	log       *[]string
+}
+
+func (g *GraphTask) Pre() error {
+	return nil
+}
+
+func (g *GraphTask) PerformAction() ([]Task, error) {
+	if g.sleep == 0 {
+		g.sleep = 20 * time.Millisecond
+	}
+	time.Sleep(g.sleep)
+	if g.permanent {
+		return nil, Permanent(errors.New("A permanent error"))
+	}
+	if g.fail {
+		return nil, errors.New("A small tiny error")
+	}
+	return nil, nil
+}
+
+func (g *GraphTask) Post() error {
+	g.mu.Lock()
+	*g.log = append(*g.log, g.name)
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *GraphTask) Name() string {
+	return g.name
+}
+
+func (g *GraphTask) DependsOn() []string {
+	return g.dependsOn
+}
+
+func newGraphTasks(failing string, deps map[string][]string, names ...string) ([]Task, *[]string) {
+	var mu sync.Mutex
+	var log []string
+	var tasks []Task
+	for _, name := range names {
+		tasks = append(tasks, &GraphTask{name: name, dependsOn: deps[name], fail: name == failing, mu: &mu, log: &log})
+	}
+	return tasks, &log
+}
+
+func indexOf(log []string, name string) int {
+	for i, n := range log {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestGraphExecutionOrder(t *testing.T) {
+	deps := map[string][]string{
+		"network": nil,
+		"vm0":     {"network"},
+		"vm1":     {"network"},
+		"lb":      {"vm0", "vm1"},
+	}
+	tasks, log := newGraphTasks("", deps, "lb", "vm1", "vm0", "network")
+	for _, task := range tasks {
+		task.(*GraphTask).sleep = 100 * time.Millisecond
+	}
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxConcurrency(2)
+	exec := New(&opts)
+	start := time.Now()
+	require.Nil(t, exec.Run(plan))
+
+	require.Len(t, *log, 4)
+	require.Equal(t, "network", (*log)[0])
+	require.Equal(t, "lb", (*log)[3])
+	// vm0 and vm1 are independent so they should run in parallel
+	require.True(t, time.Since(start) < 4*100*time.Millisecond)
+}
+
+func TestGraphFailureBlocksDependents(t *testing.T) {
+	deps := map[string][]string{
+		"vm1": {"network"},
+		"lb":  {"vm1"},
+		"dns": {"other"},
+	}
+	tasks, log := newGraphTasks("network", deps, "network", "vm1", "lb", "dns")
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(10 * time.Millisecond)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+
+	require.Equal(t, []string{"dns"}, *log)
+	require.Equal(t, 1, report.BudgetErrors)
+	require.Equal(t, -1, indexOf(*log, "lb"))
+}
+
+type RepeatedPlan struct {
+	tasks      []Task
+	iterations int
+}
+
+func (p *RepeatedPlan) Create() ([]Task, error) {
+	if p.iterations == 0 {
+		return nil, nil
+	}
+	p.iterations--
+	return p.tasks, nil
+}
+
+func TestGraphSkippedDependencyBlocksDependents(t *testing.T) {
+	tasks, log := newGraphTasks("", map[string][]string{"app": {"db"}}, "db", "app")
+	tasks[0].(*GraphTask).permanent = true
+	plan := &RepeatedPlan{tasks: tasks, iterations: 3}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0)
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+
+	require.Empty(t, *log)
+	require.Len(t, report.Iterations, 3)
+	for _, iteration := range report.Iterations[1:] {
+		require.Equal(t, []string{"db"}, iteration.Skipped)
+		require.Len(t, iteration.Tasks, 1)
+		require.Equal(t, "app", iteration.Tasks[0].Name)
+		require.True(t, iteration.Tasks[0].Blocked)
+	}
+}
+
+func TestGraphMaxConcurrency(t *testing.T) {
+	for _, maxConcurrency := range []int{1, 3} {
+		var mu sync.Mutex
+		var running, maxSeen int
+		var completed []string
+		var tasks []Task
+		for i := 0; i < 10; i++ {
+			tasks = append(tasks, &ConcurrentTask{name: fmt.Sprintf("Task%d", i), sleep: 20 * time.Millisecond,
+				mu: &mu, running: &running, maxSeen: &maxSeen, completed: &completed})
+		}
+		tasks = append(tasks, &GraphTask{name: "last", dependsOn: []string{"Task0"}, mu: &mu, log: &completed})
+		plan := &ConcurrentPlan{tasks: tasks}
+		opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxConcurrency(maxConcurrency)
+		require.Nil(t, New(&opts).Run(plan))
+		require.Len(t, completed, 11)
+		require.Equal(t, maxConcurrency, maxSeen)
+	}
+}
+
+func TestGraphWithoutDependencies(t *testing.T) {
+	// without dependencies the tasks are not a graph, so the same task can be
+	// returned twice
+	tasks, log := newGraphTasks("", nil, "a", "b", "a")
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions()
+	require.Nil(t, New(&opts).Run(plan))
+	require.Equal(t, []string{"a", "b", "a"}, *log)
+}
+
+func TestGraphCycle(t *testing.T) {
+	deps := map[string][]string{
+		"a": {"c"},
+		"b": {"a"},
+		"c": {"b"},
+	}
+	tasks, log := newGraphTasks("", deps, "a", "b", "c", "d")
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions()
+	exec := New(&opts)
+	err := exec.Run(plan)
+	require.True(t, errors.As(err, &fatalError))
+	var cycleError *DependencyCycleError
+	require.True(t, errors.As(err, &cycleError))
+	require.Equal(t, []string{"a", "b", "c", "a"}, cycleError.Cycle)
+	require.Empty(t, *log)
+}
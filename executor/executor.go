@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kouzant/execloop"
@@ -27,113 +29,509 @@ import (
 
 var fatalError *FatalError
 
+// Executor runs plans. It is safe to run several plans concurrently, or
+// one after the other, with the same Executor: the state of every run,
+// including its error budget and worker pool, is isolated from the others.
 type Executor struct {
-	options        *execloop.Options
-	numberOfErrors int
+	options *execloop.Options
+	mu      sync.Mutex
+	runs    map[string]*execution
 }
 
 func New(options *execloop.Options) *Executor {
 	return &Executor{
-		options:        options,
-		numberOfErrors: 0,
+		options: options,
+		runs:    make(map[string]*execution),
 	}
 }
 
 func (e *Executor) RunWithContext(ctx context.Context, plan Plan) error {
-	e.options.Debugf("Running with context")
+	e.options.Debug("Running with context")
+	_, err := e.RunWithResult(ctx, plan)
+	return err
+}
+
+// RunWithResult runs the plan like RunWithContext and returns a report of
+// every iteration, task and phase executed.
+func (e *Executor) RunWithResult(ctx context.Context, plan Plan) (*RunReport, error) {
+	e.options.Debug("Running with result")
+	x, ctx, err := e.start(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	defer e.done(x)
+	return e.runWithTimeout(ctx, x, plan)
+}
+
+func (e *Executor) Run(plan Plan) error {
+	e.options.Debug("Running without context")
+	x, ctx, err := e.start(context.Background(), "")
+	if err != nil {
+		return err
+	}
+	defer e.done(x)
+	err = x.run(ctx, plan)
+	x.finish(err)
+	return err
+}
+
+func (e *Executor) runWithTimeout(ctx context.Context, x *execution, plan Plan) (*RunReport, error) {
 	execCtx, cancel := context.WithTimeout(ctx, e.options.ExecutionTimeout)
 	defer cancel()
 
-	controlChannel := make(chan error)
+	controlChannel := make(chan error, 1)
 	go func() {
-		controlChannel <- e.run(plan)
+		controlChannel <- x.run(execCtx, plan)
 	}()
 
 	select {
 	case <-execCtx.Done():
-		return execCtx.Err()
+		return x.finish(execCtx.Err()), execCtx.Err()
 	case controlResponse := <-controlChannel:
-		return controlResponse
+		return x.finish(controlResponse), controlResponse
 	}
 }
 
-func (e *Executor) Run(plan Plan) error {
-	e.options.Debugf("Running without context")
-	return e.run(plan)
+// execution holds the state of a single run of a plan.
+type execution struct {
+	id        string
+	options   *execloop.Options
+	workers   chan struct{}
+	budget    *errorBudget
+	mu        sync.Mutex
+	skipped   map[string]bool
+	report    *RunReport
+	iteration *IterationReport
+	state     RunState
+	trigger   chan struct{}
+	cancel    context.CancelFunc
+	// continuous runs keep going after converging, steady is set while
+	// they stay converged
+	continuous bool
+	steady     bool
+	// cycleStart is the last iteration in which a continuous run was
+	// converged, MaxIterations counts the iterations after it
+	cycleStart int
+
+	firstIteration int
+	resumed        map[string]bool
+	resumedParents map[string][]string
+	checkpointMu   sync.Mutex
+	checkpoint     *execloop.Checkpoint
+	succeeded      []succeededTask
+	convergence    convergence
+	// fingerprint of the tasks of the last iteration
+	fingerprint string
+	limits      executionLimits
+	// abandoned holds the attempts which timed out, closed once they return
+	abandoned map[string]chan struct{}
 }
 
-func (e *Executor) run(plan Plan) error {
-	for {
-		tasks, err := plan.Create()
-		if err != nil {
+func (e *Executor) newExecution() *execution {
+	id := newRunID()
+	x := &execution{
+		id:      id,
+		options: e.options,
+		budget:  newErrorBudget(e.options),
+		skipped: make(map[string]bool),
+		report:  &RunReport{ID: id, Started: time.Now()},
+		state:   RunRunning,
+		trigger: make(chan struct{}, 1),
+
+		firstIteration: 1,
+		abandoned:      make(map[string]chan struct{}),
+	}
+	if e.options.MaxConcurrency > 1 {
+		x.workers = make(chan struct{}, e.options.MaxConcurrency)
+	}
+	return x
+}
+
+func (x *execution) run(ctx context.Context, plan Plan) (err error) {
+	ctx, span := x.startSpan(ctx, "run")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	var interval, wait time.Duration
+	for iteration := x.firstIteration; ; iteration++ {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if len(tasks) == 0 {
-			e.options.Infof("No more tasks to execute\n")
-			return nil
+		converged, clean, progressed, err := x.iterate(ctx, plan, iteration)
+		if err != nil || (converged && !x.continuous) {
+			return err
 		}
-		e.options.Debugf("Tasks remaining: %d\n", len(tasks))
-		err = e.execute(tasks)
-		if err != nil {
-			e.options.Errorf("%s. Reason: %s", err, errors.Unwrap(err))
+
+		if converged {
+			x.converged(iteration)
+			interval = 0
+			wait = x.steadyStateInterval()
+		} else {
+			x.steady = false
+			interval = x.options.NextInterval(interval, clean, progressed)
+			wait = interval
+		}
+		x.options.Debug("Waiting for next iteration", execloop.F("iteration", iteration+1),
+			execloop.F("interval", wait))
+		x.setState(RunWaiting)
+		if err := x.wait(ctx, wait); err != nil {
 			return err
 		}
+		x.setState(RunRunning)
+	}
+}
 
-		time.Sleep(e.options.SleepBetweenRuns)
+// iterate creates the plan and executes its tasks once. It reports whether
+// the plan has converged, whether every task succeeded and whether the plan
+// returned different tasks than in the previous iteration.
+func (x *execution) iterate(ctx context.Context, plan Plan, iteration int) (converged, clean, progressed bool,
+	err error) {
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnIterationStart(iteration)
+	})
+	ctx, span := x.startSpan(ctx, "iteration", execloop.Attr(AttributeIteration, iteration))
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	started := time.Now()
+	var tasks []Task
+	err = protect("", "", func() error {
+		tasks, err = create(ctx, plan)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, false, false, ctx.Err()
+		}
+		if perr, ok := err.(*PanicError); ok {
+			x.options.Error("Plan panicked", execloop.F("iteration", iteration), execloop.F("panic", perr.Value),
+				execloop.F("stack", string(perr.Stack)))
+		}
+		return false, false, false, err
+	}
+	created := time.Since(started)
+	tasks, skipped := x.withoutSkipped(tasks)
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnPlanCreated(iteration, taskNames(tasks), created)
+	})
+	if len(tasks) == 0 {
+		if !x.steady {
+			x.options.Info("No more tasks to execute", execloop.F("iteration", iteration))
+		}
+		return true, true, true, nil
 	}
+	x.options.Debug("Tasks remaining", execloop.F("iteration", iteration), execloop.F("tasks", len(tasks)))
+	fingerprint := fingerprintOf(tasks)
+	progressed = x.progressed(fingerprint)
+	if err := x.checkConvergence(iteration, tasks, fingerprint); err != nil {
+		x.options.Error("Execution stopped", execloop.F("iteration", iteration), execloop.F("error", err))
+		return false, false, false, err
+	}
+	x.budget.newIteration()
+	x.succeeded = nil
+	iterationReport := x.startIteration(iteration, skipped)
+	x.checkpointIteration(iteration)
+	clean, err = x.execute(ctx, tasks, nil)
+	x.endIteration(iterationReport)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, false, false, ctx.Err()
+		}
+		if x.options.RollbackOnFatal {
+			err = x.compensate(ctx, err)
+		}
+		x.options.Error("Execution stopped", execloop.F("iteration", iteration), execloop.F("error", err),
+			execloop.F("reason", errors.Unwrap(err)))
+		return false, false, false, err
+	}
+	x.endConvergence(fingerprint, clean)
+	return false, clean, progressed, nil
 }
 
-func (e *Executor) execute(tasks []Task) error {
+func (x *execution) execute(ctx context.Context, tasks []Task, parent *TaskReport) (bool, error) {
+	tasks = x.withoutResumed(tasks, parent)
+	if hasDependencies(tasks) {
+		return x.executeGraph(ctx, tasks, parent)
+	}
+	if x.workers != nil {
+		return x.executeConcurrently(ctx, tasks, parent)
+	}
+	succeeded := true
 	for _, task := range tasks {
-		e.options.Infof("Executing Task: %s\n", task.Name())
-		e.options.Debugf("Executing Pre of Task: %s\n", task.Name())
-		prerr := task.Pre()
-		ferr := e.handleTaskError(prerr)
-		if ferr != nil {
-			return ferr
+		ok, err := x.executeTask(ctx, task, parent)
+		if err != nil {
+			return false, err
 		}
+		succeeded = succeeded && ok
+	}
+	return succeeded, nil
+}
 
-		if prerr == nil {
-			e.options.Debugf("Executing PerfomAction of Task: %s\n", task.Name())
-			childrenTasks, paerr := task.PerformAction()
-			ferr = e.handleTaskError(paerr)
-			if ferr != nil {
-				return ferr
-			}
+func (x *execution) executeConcurrently(ctx context.Context, tasks []Task, parent *TaskReport) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			if paerr == nil {
-				e.options.Debugf("Executing Post of Task: %s\n", task.Name())
-				poerr := task.Post()
-				ferr = e.handleTaskError(poerr)
-				if ferr != nil {
-					return ferr
-				}
-				e.options.Infof("Finished executing Task: %s\n", task.Name())
-				if poerr == nil && childrenTasks != nil && len(childrenTasks) > 0 {
-					e.options.Debugf("Executig children tasks of %s\n", task.Name())
-					inerr := e.execute(childrenTasks)
-					if ferr = e.handleTaskError(inerr); ferr != nil {
-						return ferr
-					}
-				}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var ferr error
+	succeeded := true
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task Task) {
+			defer wg.Done()
+			ok, err := x.executeTask(ctx, task, parent)
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded = succeeded && ok
+			if err != nil && ferr == nil {
+				ferr = err
+				cancel()
 			}
+		}(task)
+	}
+	wg.Wait()
+	return succeeded, ferr
+}
+
+// executeTask runs a task and, once it has succeeded, its children. It
+// reports whether the task and all of its children succeeded. A task whose
+// phases completed before the run was resumed only runs its pending
+// children.
+func (x *execution) executeTask(ctx context.Context, task Task, parent *TaskReport) (bool, error) {
+	if err := x.countExecution(task); err != nil {
+		x.options.Error("Execution limit reached", x.limitFields(task)...)
+		return false, err
+	}
+	ctx, span := x.startSpan(ctx, "task", execloop.Attr(AttributeTask, task.Name()))
+	report := x.startTask(task, parent)
+	childrenTasks, ok := x.resumedChildren(task, report)
+	var err error
+	if !ok {
+		childrenTasks, ok, err = x.executePhases(ctx, task, report)
+		if err == nil && ok && len(childrenTasks) > 0 {
+			x.checkpointParent(report, childrenTasks)
 		}
 	}
-	return nil
+	if err == nil && ok && len(childrenTasks) > 0 {
+		x.options.Debug("Executing children tasks", x.taskFields(task, execloop.F("children", len(childrenTasks)))...)
+		x.options.Notify(func(o execloop.Observer) {
+			o.OnChildrenScheduled(task.Name(), taskNames(childrenTasks))
+		})
+		x.options.Notify(func(o execloop.Observer) {
+			o.OnTaskPhaseStart(task.Name(), execloop.PhaseChildren)
+		})
+		childrenCtx, childrenSpan := x.startSpan(ctx, string(execloop.PhaseChildren),
+			execloop.Attr(AttributeTask, task.Name()), execloop.Attr(AttributePhase, execloop.PhaseChildren))
+		started := time.Now()
+		ok, err = x.execute(childrenCtx, childrenTasks, report)
+		x.endPhase(task, report, execloop.PhaseChildren, started, 0, err)
+		endSpan(childrenSpan, err)
+	}
+	x.endTask(report, ok && err == nil)
+	if ok && err == nil {
+		x.checkpointTask(report)
+	}
+	endSpan(span, err)
+	return ok, err
+}
+
+// executePhases runs Pre, PerformAction and Post of a task holding a slot
+// of the worker pool. It returns the children of the task and whether all
+// phases succeeded.
+func (x *execution) executePhases(ctx context.Context, task Task, report *TaskReport) ([]Task, bool, error) {
+	slot, err := x.acquireSlot(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer slot.release()
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	x.options.Info("Executing task", x.taskFields(task)...)
+	deadline := x.newTaskDeadline(task)
+	_, prerr := x.executePhase(ctx, task, report, deadline, slot, execloop.PhasePre,
+		func(ctx context.Context) ([]Task, error) {
+			return nil, pre(ctx, task)
+		})
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if ferr := x.handleTaskError(task, prerr); ferr != nil || prerr != nil {
+		return nil, false, ferr
+	}
+
+	childrenTasks, paerr := x.executePhase(ctx, task, report, deadline, slot, execloop.PhasePerformAction,
+		func(ctx context.Context) ([]Task, error) {
+			return performAction(ctx, task)
+		})
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if ferr := x.handleTaskError(task, paerr); ferr != nil || paerr != nil {
+		return nil, false, ferr
+	}
+
+	_, poerr := x.executePhase(ctx, task, report, deadline, slot, execloop.PhasePost,
+		func(ctx context.Context) ([]Task, error) {
+			return nil, post(ctx, task)
+		})
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if ferr := x.handleTaskError(task, poerr); ferr != nil {
+		return nil, false, ferr
+	}
+	x.options.Info("Finished executing task", x.taskFields(task)...)
+	if poerr != nil {
+		return nil, false, nil
+	}
+	x.budget.success()
+	x.taskSucceeded(task, report)
+	return childrenTasks, true, nil
+}
+
+func (x *execution) executePhase(ctx context.Context, task Task, report *TaskReport, deadline taskDeadline,
+	slot *workerSlot, phase execloop.Phase, fn func(context.Context) ([]Task, error)) ([]Task, error) {
+	x.options.Debug("Executing phase", x.taskFields(task, execloop.F("phase", phase))...)
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnTaskPhaseStart(task.Name(), phase)
+	})
+	ctx, span := x.startSpan(ctx, string(phase), execloop.Attr(AttributeTask, task.Name()),
+		execloop.Attr(AttributePhase, phase))
+	started := time.Now()
+	var children []Task
+	attempts, err := x.retry(ctx, task, phase, func() error {
+		var err error
+		children, err = x.withTimeout(ctx, task, phase, deadline, slot, func(ctx context.Context) ([]Task, error) {
+			var children []Task
+			err := x.protectTask(task, phase, func() error {
+				var err error
+				children, err = fn(ctx)
+				return err
+			})
+			return children, err
+		})
+		return err
+	})
+	x.endPhase(task, report, phase, started, attempts, err)
+	span.SetAttributes(execloop.Attr(AttributeAttempts, attempts))
+	endSpan(span, err)
+	return children, err
 }
 
-func (e *Executor) handleTaskError(err error) error {
+// workerSlot is a slot of the worker pool held by a task. Phases abandoned
+// after timing out keep holding it until they return.
+type workerSlot struct {
+	workers chan struct{}
+	holders int32
+}
+
+func (x *execution) acquireSlot(ctx context.Context) (*workerSlot, error) {
+	if x.workers == nil {
+		return nil, nil
+	}
+	select {
+	case x.workers <- struct{}{}:
+		return &workerSlot{workers: x.workers, holders: 1}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *workerSlot) hold() {
+	if s != nil {
+		atomic.AddInt32(&s.holders, 1)
+	}
+}
+
+func (s *workerSlot) release() {
+	if s != nil && atomic.AddInt32(&s.holders, -1) == 0 {
+		<-s.workers
+	}
+}
+
+func (x *execution) endPhase(task Task, report *TaskReport, phase execloop.Phase, started time.Time, attempts int,
+	err error) {
+	duration := x.recordPhase(task, report, phase, started, attempts, err)
+	x.checkpointPhase(report, phase, err)
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnTaskPhaseEnd(task.Name(), phase, err, duration)
+	})
+}
+
+func (x *execution) withoutSkipped(tasks []Task) ([]Task, []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var remaining []Task
+	var skipped []string
+	for _, task := range tasks {
+		if x.skipped[task.Name()] {
+			x.options.Debug("Skipping permanently failed task", execloop.F("task", task.Name()))
+			skipped = append(skipped, task.Name())
+			continue
+		}
+		remaining = append(remaining, task)
+	}
+	return remaining, skipped
+}
+
+func (x *execution) handleTaskError(task Task, err error) error {
 	if err == nil {
 		return nil
 	}
-	e.options.Warningf("%s\n", err)
-	e.numberOfErrors++
-	if e.numberOfErrors > e.options.ErrorsToTolerate {
-		return &FatalError{fmt.Sprintf("Reached maximum number of errors to tolerate %d", e.options.ErrorsToTolerate),
-			err}
-	}
-	if errors.As(err, &fatalError) {
+	x.options.Warning("Task failed", x.taskFields(task, execloop.F("error", err))...)
+	// Fatal wins over the other wrappers anywhere in the chain
+	if IsFatal(err) {
+		x.recordError(true)
 		return err
 	}
+	if IsPermanent(err) {
+		x.options.Warning("Task failed permanently, skipping it for the rest of the run", x.taskFields(task)...)
+		x.mu.Lock()
+		x.skipped[task.Name()] = true
+		x.mu.Unlock()
+		x.recordError(false)
+		x.tolerate(task, err)
+		return nil
+	}
+	if IsRetryable(err) {
+		x.recordError(false)
+		x.tolerate(task, err)
+		return nil
+	}
+	x.recordError(true)
+	if berr := x.budget.record(err); berr != nil {
+		return &FatalError{fmt.Sprintf("Reached maximum number of errors to tolerate %d of %s budget",
+			x.options.ErrorsToTolerate, x.options.ErrorBudget), berr}
+	}
+	x.tolerate(task, err)
 	return nil
 }
+
+func (x *execution) tolerate(task Task, err error) {
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnErrorTolerated(task.Name(), err)
+	})
+}
+
+// taskFields returns the fields identifying a task in the current iteration
+// followed by the given fields.
+func (x *execution) taskFields(task Task, fields ...execloop.Field) []execloop.Field {
+	x.mu.Lock()
+	iteration := 0
+	if x.iteration != nil {
+		iteration = x.iteration.Number
+	}
+	x.mu.Unlock()
+	return append([]execloop.Field{execloop.F("iteration", iteration), execloop.F("task", task.Name())}, fields...)
+}
+
+func taskNames(tasks []Task) []string {
+	names := make([]string, len(tasks))
+	for i, task := range tasks {
+		names[i] = task.Name()
+	}
+	return names
+}
@@ -19,6 +19,8 @@ package executor
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -487,3 +489,332 @@ func TestExecutorTimeout(t *testing.T) {
 	require.NotNil(t, err)
 	require.Equal(t, err, context.DeadlineExceeded)
 }
+
+type CancellableTask struct {
+	DummyTask
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (c *CancellableTask) Pre(ctx context.Context) error {
+	return nil
+}
+
+func (c *CancellableTask) PerformAction(ctx context.Context) ([]Task, error) {
+	close(c.started)
+	<-ctx.Done()
+	close(c.cancelled)
+	return nil, ctx.Err()
+}
+
+func (c *CancellableTask) Post(ctx context.Context) error {
+	c.tasksLog[0][Post] += 1
+	return nil
+}
+
+type CancellablePlan struct {
+	task    *CancellableTask
+	creates int
+}
+
+func (p *CancellablePlan) Create(ctx context.Context) ([]Task, error) {
+	p.creates++
+	return []Task{FromContextTask(p.task)}, nil
+}
+
+func TestContextTaskCancelled(t *testing.T) {
+	var tasksLog = [][]int{
+		{0, 0, 0, 0},
+	}
+	task := &CancellableTask{
+		DummyTask: DummyTask{taskName: "CancellableTask", tasksLog: tasksLog},
+		started:   make(chan struct{}),
+		cancelled: make(chan struct{}),
+	}
+	plan := &CancellablePlan{task: task}
+	opts := execloop.DefaultOptions().WithExecutionTimeout(time.Minute)
+	exec := New(&opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-task.started
+		cancel()
+	}()
+	err := exec.RunWithContext(ctx, FromContextPlan(plan))
+	require.Equal(t, context.Canceled, err)
+
+	select {
+	case <-task.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Task did not observe cancellation")
+	}
+	require.Equal(t, 1, plan.creates)
+	require.Equal(t, 0, tasksLog[0][Post])
+}
+
+func TestSleepBetweenRunsCancelled(t *testing.T) {
+	var tasksLog = [][]int{
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+	plan := &FailOneTaskPlan{tasksLog: tasksLog, succeedAfter: 100}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Hour)
+	exec := New(&opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// run must return as soon as the context expires, not after the sleep
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.newExecution().run(ctx, plan)
+	}()
+	select {
+	case err := <-done:
+		require.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("Executor kept sleeping after the context expired")
+	}
+	require.Equal(t, 1, plan.tasksLog[1][Attempt])
+}
+
+type ConcurrentTask struct {
+	name      string
+	sleep     time.Duration
+	fail      bool
+	children  []Task
+	mu        *sync.Mutex
+	running   *int
+	maxSeen   *int
+	completed *[]string
+}
+
+func (c *ConcurrentTask) Pre() error {
+	return nil
+}
+
+func (c *ConcurrentTask) PerformAction() ([]Task, error) {
+	c.mu.Lock()
+	*c.running++
+	if *c.running > *c.maxSeen {
+		*c.maxSeen = *c.running
+	}
+	c.mu.Unlock()
+
+	time.Sleep(c.sleep)
+
+	c.mu.Lock()
+	*c.running--
+	c.mu.Unlock()
+	if c.fail {
+		return nil, errors.New("A small tiny error")
+	}
+	return c.children, nil
+}
+
+func (c *ConcurrentTask) Post() error {
+	c.mu.Lock()
+	*c.completed = append(*c.completed, c.name)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *ConcurrentTask) Name() string {
+	return c.name
+}
+
+type ConcurrentPlan struct {
+	tasks   []Task
+	created bool
+}
+
+func (p *ConcurrentPlan) Create() ([]Task, error) {
+	if p.created {
+		return nil, nil
+	}
+	p.created = true
+	return p.tasks, nil
+}
+
+func TestConcurrentExecution(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxSeen int
+	var completed []string
+	var tasks []Task
+	for i := 0; i < 20; i++ {
+		task := &ConcurrentTask{name: fmt.Sprintf("Task%d", i), sleep: 50 * time.Millisecond,
+			mu: &mu, running: &running, maxSeen: &maxSeen, completed: &completed}
+		if i == 0 {
+			task.children = []Task{
+				&ConcurrentTask{name: "Kid0", sleep: 10 * time.Millisecond,
+					mu: &mu, running: &running, maxSeen: &maxSeen, completed: &completed},
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(10 * time.Millisecond).WithMaxConcurrency(5)
+	exec := New(&opts)
+	start := time.Now()
+	err := exec.Run(plan)
+	require.Nil(t, err)
+	require.True(t, time.Since(start) < 20*50*time.Millisecond)
+
+	require.Len(t, completed, 21)
+	require.Equal(t, 5, maxSeen)
+	var parentIdx, kidIdx int
+	for i, name := range completed {
+		if name == "Task0" {
+			parentIdx = i
+		} else if name == "Kid0" {
+			kidIdx = i
+		}
+	}
+	require.True(t, parentIdx < kidIdx)
+}
+
+func TestConcurrentErrorsToTolerate(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxSeen int
+	var completed []string
+	var tasks []Task
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, &ConcurrentTask{name: fmt.Sprintf("Task%d", i), fail: true,
+			mu: &mu, running: &running, maxSeen: &maxSeen, completed: &completed})
+	}
+	plan := &ConcurrentPlan{tasks: tasks}
+	opts := execloop.DefaultOptions().WithErrorsToTolerate(10).WithMaxConcurrency(4)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 10, report.BudgetErrors)
+
+	plan = &ConcurrentPlan{tasks: tasks}
+	opts = opts.WithErrorsToTolerate(9)
+	exec = New(&opts)
+	err = exec.Run(plan)
+	require.True(t, errors.As(err, &fatalError))
+}
+
+type recordingInterval struct {
+	clean      []bool
+	progressed []bool
+}
+
+func (r *recordingInterval) Next(previous time.Duration, clean, progressed bool) time.Duration {
+	r.clean = append(r.clean, clean)
+	r.progressed = append(r.progressed, progressed)
+	return time.Millisecond
+}
+
+func TestIntervalStrategy(t *testing.T) {
+	var tasksLog = [][]int{
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+	plan := &FailOneTaskPlan{tasksLog: tasksLog, succeedAfter: 1}
+	strategy := &recordingInterval{}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Hour).WithIntervalStrategy(strategy)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(plan))
+	require.Equal(t, []bool{false, false, true}, strategy.clean)
+	// the last iteration succeeded but returned the same task as the one
+	// before it
+	require.Equal(t, []bool{true, true, false}, strategy.progressed)
+}
+
+type ClassifiedTask struct {
+	DummyTask
+	err      error
+	attempts int
+}
+
+func (c *ClassifiedTask) PerformAction() ([]Task, error) {
+	c.attempts++
+	return nil, c.err
+}
+
+type ClassifiedPlan struct {
+	task       *ClassifiedTask
+	iterations int
+}
+
+func (p *ClassifiedPlan) Create() ([]Task, error) {
+	p.iterations++
+	if p.iterations > 3 {
+		return nil, nil
+	}
+	return []Task{p.task}, nil
+}
+
+func TestFatalConstructors(t *testing.T) {
+	cause := errors.New("disk is gone")
+	err := Fatal(cause)
+	require.True(t, IsFatal(err))
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, "FatalError: disk is gone", err.Error())
+
+	err = Fatalf("could not provision %s: %w", "vm0", cause)
+	require.True(t, IsFatal(err))
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, "FatalError: could not provision vm0: disk is gone", err.Error())
+
+	require.False(t, IsFatal(cause))
+	require.False(t, IsFatal(Retryable(cause)))
+	require.True(t, IsRetryable(Retryable(cause)))
+	require.True(t, IsPermanent(Permanent(cause)))
+	require.True(t, errors.Is(Permanent(cause), cause))
+
+	require.Nil(t, Fatal(nil))
+	require.Nil(t, Retryable(nil))
+	require.Nil(t, Permanent(nil))
+}
+
+func TestFatalStopsExecution(t *testing.T) {
+	task := &ClassifiedTask{DummyTask: DummyTask{taskName: "ClassifiedTask"}, err: Fatalf("stop")}
+	plan := &ClassifiedPlan{task: task}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond)
+	exec := New(&opts)
+	require.True(t, IsFatal(exec.Run(plan)))
+	require.Equal(t, 1, task.attempts)
+}
+
+func TestFatalWinsOverOtherWrappers(t *testing.T) {
+	for _, err := range []error{
+		Fatal(Retryable(errors.New("busy"))),
+		Fatalf("giving up: %w", Permanent(errors.New("not found"))),
+		Retryable(Fatal(errors.New("disk is gone"))),
+	} {
+		task := &ClassifiedTask{DummyTask: DummyTask{taskName: "ClassifiedTask"}, err: err}
+		plan := &ClassifiedPlan{task: task}
+		opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).
+			WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2})
+		require.True(t, IsFatal(New(&opts).Run(plan)), err.Error())
+		require.Equal(t, 1, task.attempts)
+	}
+}
+
+func TestRetryableErrorIsNotCounted(t *testing.T) {
+	task := &ClassifiedTask{DummyTask: DummyTask{taskName: "ClassifiedTask"}, err: Retryable(errors.New("busy"))}
+	plan := &ClassifiedPlan{task: task}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(0).
+		WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 6, task.attempts)
+	require.Equal(t, 0, report.BudgetErrors)
+}
+
+func TestPermanentErrorSkipsTask(t *testing.T) {
+	task := &ClassifiedTask{DummyTask: DummyTask{taskName: "ClassifiedTask"}, err: Permanent(errors.New("not found"))}
+	plan := &ClassifiedPlan{task: task}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(0).
+		WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 1, task.attempts)
+	require.Equal(t, 2, plan.iterations)
+	require.Equal(t, 0, report.BudgetErrors)
+}
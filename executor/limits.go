@@ -0,0 +1,90 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kouzant/execloop"
+)
+
+// Limit names a hard cap of Options.
+type Limit string
+
+const (
+	LimitIterations          Limit = "MaxIterations"
+	LimitTaskAttempts        Limit = "MaxTaskAttempts"
+	LimitTotalTaskExecutions Limit = "MaxTotalTaskExecutions"
+)
+
+// LimitError is returned when a run reaches one of the hard caps of
+// Options. Task is set only for MaxTaskAttempts.
+type LimitError struct {
+	Limit Limit
+	Task  string
+	Count int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	if e.Task != "" {
+		return fmt.Sprintf("Reached %s limit, task %s was executed %d times out of %d", e.Limit, e.Task, e.Count, e.Max)
+	}
+	return fmt.Sprintf("Reached %s limit, %d out of %d", e.Limit, e.Count, e.Max)
+}
+
+// executionLimits counts the executions of tasks in a run.
+type executionLimits struct {
+	mu       sync.Mutex
+	attempts map[string]int
+	total    int
+}
+
+// countExecution counts an execution of a task, failing when it would
+// exceed MaxTaskAttempts or MaxTotalTaskExecutions.
+func (x *execution) countExecution(task Task) error {
+	l := &x.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max := x.options.MaxTotalTaskExecutions; max > 0 && l.total >= max {
+		return &LimitError{Limit: LimitTotalTaskExecutions, Count: l.total, Max: max}
+	}
+	if max := x.options.MaxTaskAttempts; max > 0 && l.attempts[task.Name()] >= max {
+		return &LimitError{Limit: LimitTaskAttempts, Task: task.Name(), Count: l.attempts[task.Name()], Max: max}
+	}
+	if l.attempts == nil {
+		l.attempts = make(map[string]int)
+	}
+	l.attempts[task.Name()]++
+	l.total++
+	return nil
+}
+
+func (l *executionLimits) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts = nil
+	l.total = 0
+}
+
+func (x *execution) limitFields(task Task) []execloop.Field {
+	x.limits.mu.Lock()
+	defer x.limits.mu.Unlock()
+	return x.taskFields(task, execloop.F("attempts", x.limits.attempts[task.Name()]),
+		execloop.F("executions", x.limits.total))
+}
@@ -0,0 +1,55 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxTaskAttempts(t *testing.T) {
+	plan := &StuckPlan{progressing: true}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxTaskAttempts(3)
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	var limitError *LimitError
+	require.True(t, errors.As(err, &limitError))
+	require.Equal(t, &LimitError{Limit: LimitTaskAttempts, Task: "stuck", Count: 3, Max: 3}, limitError)
+	require.Equal(t, StopLimitReached, report.Reason)
+	require.Equal(t, 3, report.Tasks)
+}
+
+func TestMaxTotalTaskExecutions(t *testing.T) {
+	tasksLog := newTasksLog()
+	plan := &ChildrenPlan{tasksLog: tasksLog}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithMaxTotalTaskExecutions(2)
+	_, err := New(&opts).RunWithResult(context.Background(), plan)
+	var limitError *LimitError
+	require.True(t, errors.As(err, &limitError))
+	require.Equal(t, LimitTotalTaskExecutions, limitError.Limit)
+	require.Equal(t, 2, limitError.Count)
+	require.Equal(t, "Reached MaxTotalTaskExecutions limit, 2 out of 2", err.Error())
+	require.Equal(t, 1, tasksLog[1][PerformAction])
+	require.Equal(t, 0, tasksLog[2][PerformAction])
+
+	opts = opts.WithMaxTotalTaskExecutions(3)
+	err = New(&opts).Run(&ChildrenPlan{tasksLog: newTasksLog()})
+	require.Nil(t, err)
+}
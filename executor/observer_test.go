@@ -0,0 +1,125 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingObserver) record(format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, a...))
+}
+
+func (r *recordingObserver) OnIterationStart(iteration int) {
+	r.record("iteration %d", iteration)
+}
+
+func (r *recordingObserver) OnPlanCreated(iteration int, tasks []string, duration time.Duration) {
+	r.record("plan %d %v", iteration, tasks)
+}
+
+func (r *recordingObserver) OnTaskPhaseStart(task string, phase execloop.Phase) {
+	r.record("start %s %s", task, phase)
+}
+
+func (r *recordingObserver) OnTaskPhaseEnd(task string, phase execloop.Phase, err error, duration time.Duration) {
+	r.record("end %s %s %v", task, phase, err)
+}
+
+func (r *recordingObserver) OnChildrenScheduled(task string, children []string) {
+	r.record("children %s %v", task, children)
+}
+
+func (r *recordingObserver) OnErrorTolerated(task string, err error) {
+	r.record("tolerated %s %v", task, err)
+}
+
+func (r *recordingObserver) OnRunFinished(err error, duration time.Duration) {
+	r.record("finished %v", err)
+}
+
+func (r *recordingObserver) OnConverged(iteration int) {
+	r.record("converged %d", iteration)
+}
+
+type panickingObserver struct {
+	execloop.NoopObserver
+}
+
+func (p *panickingObserver) OnTaskPhaseStart(task string, phase execloop.Phase) {
+	panic("observer is broken")
+}
+
+func TestObserver(t *testing.T) {
+	plan := &ChildrenPlan{tasksLog: newTasksLog()}
+	observer := &recordingObserver{}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).
+		WithObserver(&panickingObserver{}).WithObserver(observer)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(plan))
+
+	require.Equal(t, []string{
+		"iteration 1",
+		"plan 1 [ParentTask0]",
+		"start ParentTask0 Pre",
+		"end ParentTask0 Pre <nil>",
+		"start ParentTask0 PerformAction",
+		"end ParentTask0 PerformAction <nil>",
+		"start ParentTask0 Post",
+		"end ParentTask0 Post <nil>",
+		"children ParentTask0 [KidTask0 KidTask1]",
+		"start ParentTask0 Children",
+		"start KidTask0 Pre",
+		"end KidTask0 Pre <nil>",
+		"start KidTask0 PerformAction",
+		"end KidTask0 PerformAction <nil>",
+		"start KidTask0 Post",
+		"end KidTask0 Post <nil>",
+		"start KidTask1 Pre",
+		"end KidTask1 Pre <nil>",
+		"start KidTask1 PerformAction",
+		"end KidTask1 PerformAction <nil>",
+		"start KidTask1 Post",
+		"end KidTask1 Post <nil>",
+		"end ParentTask0 Children <nil>",
+		"iteration 2",
+		"plan 2 []",
+		"finished <nil>",
+	}, observer.events)
+}
+
+func TestObserverErrorTolerated(t *testing.T) {
+	plan := &FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 0}
+	observer := &recordingObserver{}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithObserver(observer)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(plan))
+	require.Contains(t, observer.events, "tolerated DummyTask1 A small tiny error")
+}
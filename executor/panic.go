@@ -0,0 +1,64 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/kouzant/execloop"
+)
+
+// PanicError is returned when a task or a plan panics. Task and Phase are
+// empty when Plan.Create panicked.
+type PanicError struct {
+	Task  string
+	Phase execloop.Phase
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Task == "" {
+		return fmt.Sprintf("Plan panicked while creating tasks: %v", e.Value)
+	}
+	return fmt.Sprintf("Task %s panicked during %s: %v", e.Task, e.Phase, e.Value)
+}
+
+// protect runs fn turning a panic into a PanicError.
+func protect(task string, phase execloop.Phase, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Task: task, Phase: phase, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// protectTask runs a phase of a task turning a panic into a PanicError,
+// which is fatal when PanicsAreFatal is set.
+func (x *execution) protectTask(task Task, phase execloop.Phase, fn func() error) error {
+	err := protect(task.Name(), phase, fn)
+	if perr, ok := err.(*PanicError); ok {
+		x.options.Error("Task panicked", x.taskFields(task, execloop.F("phase", phase),
+			execloop.F("panic", perr.Value), execloop.F("stack", string(perr.Stack)))...)
+		if x.options.PanicsAreFatal {
+			return Fatal(err)
+		}
+	}
+	return err
+}
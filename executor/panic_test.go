@@ -0,0 +1,104 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type PanickingTask struct {
+	DummyTask
+}
+
+func (p *PanickingTask) PerformAction() ([]Task, error) {
+	panic("boom")
+}
+
+type PanickingSagaTask struct {
+	DummyTask
+}
+
+func (p *PanickingSagaTask) Compensate(ctx context.Context, cause error) error {
+	panic("cannot compensate")
+}
+
+type PanickingPlan struct{}
+
+func (p *PanickingPlan) Create() ([]Task, error) {
+	var tasks []Task
+	return tasks[:1], nil
+}
+
+func TestPanicInPhaseIsTolerated(t *testing.T) {
+	plan := &ConcurrentPlan{tasks: []Task{&PanickingTask{DummyTask{taskName: "panicky"}}}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0)
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 1, report.BudgetErrors)
+	var panicError *PanicError
+	require.True(t, errors.As(report.Iterations[0].Tasks[0].Phases[1].Err, &panicError))
+	require.Equal(t, "panicky", panicError.Task)
+	require.Equal(t, execloop.PhasePerformAction, panicError.Phase)
+	require.Equal(t, "boom", panicError.Value)
+	require.Contains(t, string(panicError.Stack), "PerformAction")
+	require.Equal(t, "Task panicky panicked during PerformAction: boom", panicError.Error())
+}
+
+func TestPanicsAreFatal(t *testing.T) {
+	plan := &ConcurrentPlan{tasks: []Task{&PanickingTask{DummyTask{taskName: "panicky"}}}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithPanicsAreFatal(true).
+		WithRollbackOnFatal(true)
+	err := New(&opts).RunWithContext(context.Background(), plan)
+	require.True(t, IsFatal(err))
+	var panicError *PanicError
+	require.True(t, errors.As(err, &panicError))
+}
+
+func TestPanicInCompensate(t *testing.T) {
+	var compensated []string
+	vm := &SagaTask{DummyTask: DummyTask{taskName: "vm"}, compensated: &compensated}
+	panicky := &PanickingSagaTask{DummyTask{taskName: "panicky"}}
+	plan := &ConcurrentPlan{tasks: []Task{panicky, vm, &SagaTask{DummyTask: DummyTask{taskName: "lb"},
+		compensated: &compensated, err: Fatalf("no quota")}}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithRollbackOnFatal(true)
+	err := New(&opts).RunWithContext(context.Background(), plan)
+	var compensationError *CompensationError
+	require.True(t, errors.As(err, &compensationError))
+	require.Equal(t, []string{"vm"}, compensated)
+	require.Len(t, compensationError.Results, 2)
+	var panicError *PanicError
+	require.True(t, errors.As(compensationError.Results[1].Err, &panicError))
+	require.Equal(t, execloop.PhaseCompensate, panicError.Phase)
+}
+
+func TestPanicInCreate(t *testing.T) {
+	opts := execloop.DefaultOptions().WithExecutionTimeout(time.Minute)
+	report, err := New(&opts).RunWithResult(context.Background(), &PanickingPlan{})
+	var panicError *PanicError
+	require.True(t, errors.As(err, &panicError))
+	require.Equal(t, "", panicError.Task)
+	require.Equal(t, StopFatal, report.Reason)
+
+	_, err = New(&opts).DryRun(&PanickingPlan{})
+	require.True(t, errors.As(err, &panicError))
+}
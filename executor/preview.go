@@ -0,0 +1,126 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kouzant/execloop"
+)
+
+// Previewable is a Task which can describe what it would do and which
+// children it would create without performing any side effects.
+type Previewable interface {
+	Preview() (string, []Task)
+}
+
+type PreviewNode struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	DependsOn   []string       `json:"dependsOn,omitempty"`
+	Children    []*PreviewNode `json:"children,omitempty"`
+}
+
+// Preview is the tree of tasks a plan would execute in its next iteration.
+type Preview struct {
+	Tasks []*PreviewNode `json:"tasks"`
+}
+
+// DryRun asks the plan for its tasks and previews them, and the children
+// they would create, without executing any of their phases.
+func (e *Executor) DryRun(plan Plan) (*Preview, error) {
+	e.options.Debug("Dry run")
+	var tasks []Task
+	err := protect("", "", func() error {
+		var err error
+		tasks, err = create(context.Background(), plan)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hasDependencies(tasks) {
+		if _, err := newTaskGraph(tasks); err != nil {
+			return nil, err
+		}
+	}
+	e.options.Info("Previewing tasks", execloop.F("tasks", len(tasks)))
+	return &Preview{previewTasks(tasks)}, nil
+}
+
+func previewTasks(tasks []Task) []*PreviewNode {
+	nodes := make([]*PreviewNode, 0, len(tasks))
+	for _, task := range tasks {
+		node := &PreviewNode{
+			Name:      task.Name(),
+			DependsOn: dependenciesOf(task),
+		}
+		if p, ok := implementation(task).(Previewable); ok {
+			var children []Task
+			node.Description, children = p.Preview()
+			if len(children) > 0 {
+				node.Children = previewTasks(children)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Render writes the preview as an indented tree.
+func (p *Preview) Render(w io.Writer) error {
+	_, err := io.WriteString(w, p.String())
+	return err
+}
+
+// RenderJSON writes the preview as indented JSON.
+func (p *Preview) RenderJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (p *Preview) String() string {
+	var b strings.Builder
+	renderNodes(&b, p.Tasks, "")
+	return b.String()
+}
+
+func renderNodes(b *strings.Builder, nodes []*PreviewNode, indent string) {
+	for i, node := range nodes {
+		branch, childIndent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+		b.WriteString(indent + branch + node.Name)
+		if len(node.DependsOn) > 0 {
+			fmt.Fprintf(b, " (after %s)", strings.Join(node.DependsOn, ", "))
+		}
+		if node.Description != "" {
+			b.WriteString(": " + node.Description)
+		}
+		b.WriteString("\n")
+		renderNodes(b, node.Children, indent+childIndent)
+	}
+}
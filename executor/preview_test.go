@@ -0,0 +1,79 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type PreviewTask struct {
+	GraphTask
+	description string
+	children    []Task
+	executed    bool
+}
+
+func (p *PreviewTask) PerformAction() ([]Task, error) {
+	p.executed = true
+	return p.children, nil
+}
+
+func (p *PreviewTask) Preview() (string, []Task) {
+	return p.description, p.children
+}
+
+func TestDryRun(t *testing.T) {
+	disk := &PreviewTask{GraphTask: GraphTask{name: "disk0"}, description: "Attach a 10G disk"}
+	vm := &PreviewTask{GraphTask: GraphTask{name: "vm0"}, description: "Create VM", children: []Task{
+		disk,
+		&DummyTask{taskName: "nic0"},
+	}}
+	lb := &PreviewTask{GraphTask: GraphTask{name: "lb", dependsOn: []string{"vm0"}}, description: "Create LB"}
+	plan := &ConcurrentPlan{tasks: []Task{vm, lb}}
+	opts := execloop.DefaultOptions()
+	exec := New(&opts)
+	preview, err := exec.DryRun(plan)
+	require.Nil(t, err)
+	require.False(t, vm.executed)
+	require.False(t, disk.executed)
+
+	require.Equal(t, "├── vm0: Create VM\n"+
+		"│   ├── disk0: Attach a 10G disk\n"+
+		"│   └── nic0\n"+
+		"└── lb (after vm0): Create LB\n", preview.String())
+
+	var out bytes.Buffer
+	require.Nil(t, preview.RenderJSON(&out))
+	var decoded Preview
+	require.Nil(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Equal(t, preview, &decoded)
+}
+
+func TestDryRunCycle(t *testing.T) {
+	a := &GraphTask{name: "a", dependsOn: []string{"b"}}
+	b := &GraphTask{name: "b", dependsOn: []string{"a"}}
+	opts := execloop.DefaultOptions()
+	_, err := New(&opts).DryRun(&ConcurrentPlan{tasks: []Task{a, b}})
+	var cycleError *DependencyCycleError
+	require.True(t, errors.As(err, &cycleError))
+}
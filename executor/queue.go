@@ -0,0 +1,94 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/workqueue"
+)
+
+// KeyedPlan creates the tasks which bring the object identified by a key of
+// a work queue to its final state.
+type KeyedPlan interface {
+	Create(key string) ([]Task, error)
+}
+
+// keyedPlan is the Plan of a single key.
+type keyedPlan struct {
+	plan KeyedPlan
+	key  string
+}
+
+func (k *keyedPlan) Create() ([]Task, error) {
+	return k.plan.Create(k.key)
+}
+
+// Drain processes the keys of the queue with QueueWorkers workers until ctx
+// is done or the queue is shut down. Every key is run like RunWithResult
+// with the plan KeyedPlan creates for it. Keys whose run fails are requeued
+// with the backoff of the queue, unless the error is Permanent, for example
+// when KeyedPlan.Create returns one.
+func (e *Executor) Drain(ctx context.Context, queue *workqueue.Queue, plan KeyedPlan) error {
+	workers := e.options.QueueWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	e.options.Info("Draining work queue", execloop.F("workers", workers))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				key, ok := queue.Get(ctx)
+				if !ok {
+					return
+				}
+				e.processKey(ctx, queue, plan, key)
+			}
+		}()
+	}
+	wg.Wait()
+	e.options.Info("Stopped draining work queue")
+	return nil
+}
+
+func (e *Executor) processKey(ctx context.Context, queue *workqueue.Queue, plan KeyedPlan, key string) {
+	defer queue.Done(key)
+	e.options.Debug("Processing key", execloop.F("key", key))
+	report, err := e.RunWithResult(ctx, &keyedPlan{plan, key})
+	switch {
+	case err == nil:
+		queue.Forget(key)
+	case ctx.Err() != nil:
+		queue.Add(key)
+	case IsPermanent(err):
+		e.options.Error("Dropping key", execloop.F("key", key), execloop.F("error", err))
+		queue.Forget(key)
+	default:
+		e.options.Warning("Requeueing key", execloop.F("key", key), execloop.F("error", err),
+			execloop.F("requeues", queue.NumRequeues(key)+1))
+		queue.AddRateLimited(key)
+	}
+	if report != nil {
+		e.options.Debug("Processed key", execloop.F("key", key), execloop.F("run", report.ID),
+			execloop.F("reason", report.Reason))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/workqueue"
+	"github.com/stretchr/testify/require"
+)
+
+// ObjectsPlan reconciles objects by key, failing the first time it creates
+// the plan of a key listed in flaky.
+type ObjectsPlan struct {
+	mu         sync.Mutex
+	flaky      map[string]bool
+	reconciled map[string]int
+	active     map[string]bool
+	overlaps   int
+	done       chan string
+}
+
+func (p *ObjectsPlan) Create(key string) ([]Task, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.flaky[key] {
+		delete(p.flaky, key)
+		return nil, errors.New("A small tiny error")
+	}
+	if key == "gone" {
+		return nil, Permanent(errors.New("object is gone"))
+	}
+	if p.reconciled[key] > 0 {
+		return nil, nil
+	}
+	return []Task{&ObjectTask{DummyTask: DummyTask{taskName: key}, plan: p}}, nil
+}
+
+type ObjectTask struct {
+	DummyTask
+	plan *ObjectsPlan
+}
+
+func (o *ObjectTask) PerformAction() ([]Task, error) {
+	o.plan.mu.Lock()
+	if o.plan.active[o.taskName] {
+		o.plan.overlaps++
+	}
+	o.plan.active[o.taskName] = true
+	o.plan.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	o.plan.mu.Lock()
+	delete(o.plan.active, o.taskName)
+	o.plan.reconciled[o.taskName]++
+	o.plan.mu.Unlock()
+	o.plan.done <- o.taskName
+	return nil, nil
+}
+
+func TestDrain(t *testing.T) {
+	plan := &ObjectsPlan{flaky: map[string]bool{"b": true}, reconciled: make(map[string]int),
+		active: make(map[string]bool), done: make(chan string, 10)}
+	queue := workqueue.NewWithBackoff(execloop.RetryPolicy{InitialDelay: time.Millisecond})
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithQueueWorkers(4)
+	exec := New(&opts)
+	drained := make(chan error)
+	go func() {
+		drained <- exec.Drain(context.Background(), queue, plan)
+	}()
+	for _, key := range []string{"a", "b", "a", "gone", "c", "a"} {
+		queue.Add(key)
+	}
+	reconciled := make(map[string]bool)
+	for len(reconciled) < 3 {
+		reconciled[<-plan.done] = true
+	}
+	queue.ShutDown()
+	require.Nil(t, <-drained)
+
+	require.Equal(t, 0, plan.overlaps)
+	require.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, reconciled)
+	require.Equal(t, 0, queue.NumRequeues("b"))
+	require.Equal(t, 0, queue.NumRequeues("gone"))
+}
+
+func TestDrainStopsWithContext(t *testing.T) {
+	plan := &ObjectsPlan{reconciled: make(map[string]int), active: make(map[string]bool),
+		done: make(chan string, 10)}
+	queue := workqueue.New()
+	opts := execloop.DefaultOptions()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Nil(t, New(&opts).Drain(ctx, queue, plan))
+	require.False(t, queue.ShuttingDown())
+}
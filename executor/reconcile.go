@@ -0,0 +1,85 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// Reconcile runs the plan continuously. Unlike Run it doesn't return when
+// the plan converges: it reports the final state to the observers and keeps
+// creating the plan every SteadyStateInterval, or when triggered, executing
+// the tasks it returns whenever the system drifts. The limits and the error
+// budget apply to each drift separately. ExecutionTimeout does not apply.
+// Reconcile returns nil when ctx is done or Stop is called, or the error
+// which stopped the run.
+func (e *Executor) Reconcile(ctx context.Context, plan Plan) error {
+	e.options.Debug("Reconciling")
+	x, ctx, err := e.start(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer e.done(x)
+	x.continuous = true
+	err = x.run(ctx, plan)
+	x.finish(err)
+	if ctx.Err() != nil && err == ctx.Err() {
+		x.options.Info("Stopped reconciling", execloop.F("run", x.id))
+		return nil
+	}
+	return err
+}
+
+// converged notifies the observers when the plan reaches its final state.
+func (x *execution) converged(iteration int) {
+	x.newCycle(iteration)
+	if x.steady {
+		return
+	}
+	x.steady = true
+	x.options.Info("Plan converged", execloop.F("run", x.id), execloop.F("iteration", iteration))
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnConverged(iteration)
+	})
+}
+
+// steadyStateInterval falls back to SleepBetweenRuns when no
+// SteadyStateInterval is set.
+func (x *execution) steadyStateInterval() time.Duration {
+	if x.options.SteadyStateInterval > 0 {
+		return x.options.SteadyStateInterval
+	}
+	return x.options.SleepBetweenRuns
+}
+
+// newCycle starts a new reconcile cycle after the given iteration. The
+// limits, the error budget and the tasks skipped for the run apply to a
+// cycle, and the report keeps only the iterations of the current one.
+func (x *execution) newCycle(iteration int) {
+	x.cycleStart = iteration
+	x.convergence = convergence{}
+	x.fingerprint = ""
+	x.budget.reset()
+	x.limits.reset()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.skipped = make(map[string]bool)
+	x.report.Iterations = nil
+}
@@ -0,0 +1,172 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+// DriftPlan converges once actual equals desired.
+type DriftPlan struct {
+	mu      sync.Mutex
+	desired int
+	actual  int
+	fatal   bool
+	// flaky plans fail the first attempt to reconcile every drift
+	flaky     bool
+	failedFor int
+}
+
+func (p *DriftPlan) Create() ([]Task, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.actual == p.desired {
+		return nil, nil
+	}
+	if p.fatal {
+		return nil, Fatalf("cannot reconcile")
+	}
+	return []Task{&DriftTask{DummyTask: DummyTask{taskName: "DriftTask"}, plan: p}}, nil
+}
+
+func (p *DriftPlan) drift() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.desired++
+}
+
+type DriftTask struct {
+	DummyTask
+	plan *DriftPlan
+}
+
+func (d *DriftTask) PerformAction() ([]Task, error) {
+	d.plan.mu.Lock()
+	defer d.plan.mu.Unlock()
+	if d.plan.flaky && d.plan.failedFor != d.plan.desired {
+		d.plan.failedFor = d.plan.desired
+		return nil, errors.New("A small tiny error")
+	}
+	d.plan.actual = d.plan.desired
+	return nil, nil
+}
+
+type convergedObserver struct {
+	execloop.NoopObserver
+	converged chan int
+}
+
+func (c *convergedObserver) OnConverged(iteration int) {
+	c.converged <- iteration
+}
+
+func TestReconcile(t *testing.T) {
+	plan := &DriftPlan{desired: 1}
+	observer := &convergedObserver{converged: make(chan int)}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).
+		WithSteadyStateInterval(time.Millisecond).WithObserver(observer)
+	exec := New(&opts)
+	done := make(chan error)
+	go func() {
+		done <- exec.Reconcile(context.Background(), plan)
+	}()
+	require.Equal(t, 2, <-observer.converged)
+
+	plan.drift()
+	iteration := <-observer.converged
+	require.True(t, iteration > 2)
+	require.Len(t, exec.Runs(), 1)
+
+	exec.Stop()
+	require.Nil(t, <-done)
+	require.Empty(t, exec.Runs())
+	require.Equal(t, 2, plan.actual)
+}
+
+func TestReconcileResetsCycles(t *testing.T) {
+	plan := &DriftPlan{desired: 1, flaky: true}
+	observer := &convergedObserver{converged: make(chan int)}
+	// every drift takes two iterations and two attempts with one error, the
+	// limits apply to each of them and not to the polls in between
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).
+		WithSteadyStateInterval(time.Millisecond).WithObserver(observer).WithErrorsToTolerate(1).
+		WithMaxIterations(2).WithMaxTaskAttempts(2)
+	exec := New(&opts)
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Reconcile(context.Background(), plan)
+	}()
+	require.Equal(t, 3, <-observer.converged)
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		plan.drift()
+		select {
+		case <-observer.converged:
+		case err := <-done:
+			t.Fatalf("Reconcile stopped: %v", err)
+		}
+	}
+
+	exec.mu.Lock()
+	for _, x := range exec.runs {
+		x.mu.Lock()
+		require.Empty(t, x.report.Iterations)
+		x.mu.Unlock()
+	}
+	exec.mu.Unlock()
+	exec.Stop()
+	require.Nil(t, <-done)
+	require.Equal(t, 4, plan.actual)
+}
+
+func TestReconcileWaitsForTrigger(t *testing.T) {
+	plan := &DriftPlan{}
+	observer := &convergedObserver{converged: make(chan int, 2)}
+	opts := execloop.DefaultOptions().WithSteadyStateInterval(time.Hour).WithWaitForTrigger(true).
+		WithObserver(observer)
+	exec := New(&opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- exec.Reconcile(ctx, plan)
+	}()
+	require.Equal(t, 1, <-observer.converged)
+
+	plan.drift()
+	waitForState(t, exec, RunWaiting)
+	exec.Trigger()
+	waitForState(t, exec, RunWaiting)
+	exec.Trigger()
+	require.Equal(t, 3, <-observer.converged)
+
+	cancel()
+	require.Nil(t, <-done)
+}
+
+func TestReconcileFatal(t *testing.T) {
+	plan := &DriftPlan{desired: 1, fatal: true}
+	opts := execloop.DefaultOptions().WithSteadyStateInterval(time.Millisecond)
+	err := New(&opts).Reconcile(context.Background(), plan)
+	require.True(t, IsFatal(err))
+}
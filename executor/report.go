@@ -0,0 +1,252 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// StopReason tells why a run of the executor stopped.
+type StopReason string
+
+const (
+	StopConverged       StopReason = "converged"
+	StopFatal           StopReason = "fatal"
+	StopBudgetExhausted StopReason = "budget-exhausted"
+	StopNotConverging   StopReason = "not-converging"
+	StopLimitReached    StopReason = "limit-reached"
+	StopTimeout         StopReason = "timeout"
+	StopCancelled       StopReason = "cancelled"
+)
+
+type RunReport struct {
+	ID         string             `json:"id"`
+	Started    time.Time          `json:"started"`
+	Duration   time.Duration      `json:"duration"`
+	Iterations []*IterationReport `json:"iterations"`
+	// Tasks is the number of task executions, including children
+	Tasks int `json:"tasks"`
+	// Errors is the number of errors returned by the tasks
+	Errors int `json:"errors"`
+	// BudgetErrors is the number of errors counted towards ErrorsToTolerate
+	BudgetErrors int        `json:"budgetErrors"`
+	Reason       StopReason `json:"reason"`
+	Err          error      `json:"-"`
+	Error        string     `json:"error,omitempty"`
+}
+
+type IterationReport struct {
+	Number   int           `json:"number"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Tasks    []*TaskReport `json:"tasks"`
+	// Skipped are the tasks returned by the plan which failed permanently
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+type TaskReport struct {
+	Name     string        `json:"name"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Phases   []PhaseReport `json:"phases"`
+	Children []*TaskReport `json:"children,omitempty"`
+	// Blocked is set when the task was not executed because a task it
+	// depends on failed
+	Blocked   bool `json:"blocked,omitempty"`
+	Succeeded bool `json:"succeeded"`
+	// path identifies the task in the checkpoints
+	path string
+}
+
+type PhaseReport struct {
+	Phase    execloop.Phase `json:"phase"`
+	Duration time.Duration  `json:"duration"`
+	Attempts int            `json:"attempts,omitempty"`
+	Err      error          `json:"-"`
+	Error    string         `json:"error,omitempty"`
+	// Stdout and Stderr are the output of the phase of an OutputTask
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// OutputTask exposes the output of the last attempt of a phase of a task,
+// such as the stdout and stderr of a command, to record it in the
+// RunReport.
+type OutputTask interface {
+	Output(phase execloop.Phase) (stdout, stderr string)
+}
+
+// ReasonOf tells why a run which returned err stopped. A run interrupted by
+// its context returns the error of the context itself, only then the reason
+// is a timeout or a cancellation: errors which merely wrap
+// context.DeadlineExceeded, such as the timeout of a request made by a task,
+// are classified by the error which wraps them. A plan which did not converge
+// within MaxIterations reached a limit, like the other execution limits.
+func ReasonOf(err error) StopReason {
+	var budgetError *BudgetExhaustedError
+	var convergenceError *NotConvergingError
+	var limitError *LimitError
+	switch {
+	case err == nil:
+		return StopConverged
+	case errors.As(err, &budgetError):
+		return StopBudgetExhausted
+	case errors.As(err, &limitError):
+		return StopLimitReached
+	case errors.As(err, &convergenceError):
+		return StopNotConverging
+	case err == context.DeadlineExceeded:
+		return StopTimeout
+	case err == context.Canceled:
+		return StopCancelled
+	default:
+		return StopFatal
+	}
+}
+
+func (r *RunReport) copy() *RunReport {
+	c := *r
+	c.Iterations = make([]*IterationReport, len(r.Iterations))
+	for i, iteration := range r.Iterations {
+		ic := *iteration
+		ic.Tasks = copyTaskReports(iteration.Tasks)
+		ic.Skipped = append([]string(nil), iteration.Skipped...)
+		c.Iterations[i] = &ic
+	}
+	return &c
+}
+
+func copyTaskReports(reports []*TaskReport) []*TaskReport {
+	if reports == nil {
+		return nil
+	}
+	c := make([]*TaskReport, len(reports))
+	for i, report := range reports {
+		rc := *report
+		rc.Phases = append([]PhaseReport(nil), report.Phases...)
+		rc.Children = copyTaskReports(report.Children)
+		c[i] = &rc
+	}
+	return c
+}
+
+func (x *execution) startIteration(number int, skipped []string) *IterationReport {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	iteration := &IterationReport{
+		Number:  number,
+		Started: time.Now(),
+		Skipped: skipped,
+	}
+	x.report.Iterations = append(x.report.Iterations, iteration)
+	x.iteration = iteration
+	return iteration
+}
+
+func (x *execution) endIteration(iteration *IterationReport) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	iteration.Duration = time.Since(iteration.Started)
+}
+
+func (x *execution) startTask(task Task, parent *TaskReport) *TaskReport {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	report := &TaskReport{
+		Name:    task.Name(),
+		Started: time.Now(),
+		path:    taskPath(parent, task.Name()),
+	}
+	x.addTask(report, parent)
+	x.report.Tasks++
+	return report
+}
+
+func (x *execution) blockTask(task Task, parent *TaskReport) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.addTask(&TaskReport{Name: task.Name(), Blocked: true, path: taskPath(parent, task.Name())}, parent)
+}
+
+func (x *execution) addTask(report *TaskReport, parent *TaskReport) {
+	if parent != nil {
+		parent.Children = append(parent.Children, report)
+	} else {
+		x.iteration.Tasks = append(x.iteration.Tasks, report)
+	}
+}
+
+func (x *execution) endTask(report *TaskReport, succeeded bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	report.Duration = time.Since(report.Started)
+	report.Succeeded = succeeded
+}
+
+func (x *execution) recordPhase(task Task, report *TaskReport, phase execloop.Phase, started time.Time,
+	attempts int, err error) time.Duration {
+	phaseReport := PhaseReport{
+		Phase:    phase,
+		Duration: time.Since(started),
+		Attempts: attempts,
+		Err:      err,
+	}
+	if t, ok := implementation(task).(OutputTask); ok {
+		phaseReport.Stdout, phaseReport.Stderr = t.Output(phase)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err != nil {
+		phaseReport.Error = err.Error()
+	}
+	report.Phases = append(report.Phases, phaseReport)
+	return phaseReport.Duration
+}
+
+func (x *execution) recordError(counted bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.report.Errors++
+	if counted {
+		x.report.BudgetErrors++
+	}
+}
+
+// finish completes the report of the run with the error it stopped with
+// and returns a copy of it, which is safe to use even if the run is still
+// winding down in the background.
+func (x *execution) finish(err error) *RunReport {
+	x.mu.Lock()
+	x.report.Duration = time.Since(x.report.Started)
+	x.report.Reason = ReasonOf(err)
+	x.report.Err = err
+	if err != nil {
+		x.report.Error = err.Error()
+	}
+	report := x.report.copy()
+	x.mu.Unlock()
+
+	x.checkpointFinished(err)
+	x.options.Notify(func(o execloop.Observer) {
+		o.OnRunFinished(err, report.Duration)
+	})
+	return report
+}
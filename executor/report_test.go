@@ -0,0 +1,104 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportConverged(t *testing.T) {
+	plan := &ChildrenPlan{tasksLog: newTasksLog()}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+
+	require.Equal(t, StopConverged, report.Reason)
+	require.Len(t, report.Iterations, 1)
+	require.Equal(t, 3, report.Tasks)
+	require.Equal(t, 0, report.Errors)
+
+	parent := report.Iterations[0].Tasks[0]
+	require.Equal(t, "ParentTask0", parent.Name)
+	require.True(t, parent.Succeeded)
+	var phases []execloop.Phase
+	for _, phase := range parent.Phases {
+		phases = append(phases, phase.Phase)
+	}
+	require.Equal(t, []execloop.Phase{execloop.PhasePre, execloop.PhasePerformAction, execloop.PhasePost,
+		execloop.PhaseChildren}, phases)
+	require.Equal(t, 1, parent.Phases[1].Attempts)
+	require.Len(t, parent.Children, 2)
+	require.Equal(t, "KidTask0", parent.Children[0].Name)
+	require.Equal(t, "KidTask1", parent.Children[1].Name)
+
+	_, err = json.Marshal(report)
+	require.Nil(t, err)
+}
+
+func TestReportRetriedAttempts(t *testing.T) {
+	task := &FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 1}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 3})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 2, report.Iterations[0].Tasks[0].Phases[1].Attempts)
+	require.Nil(t, report.Iterations[0].Tasks[0].Phases[1].Err)
+}
+
+func TestReportStopReasons(t *testing.T) {
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond)
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), &FailFatalPlan{tasksLog: newTasksLog()})
+	require.True(t, IsFatal(err))
+	require.Equal(t, StopFatal, report.Reason)
+	require.Equal(t, err, report.Err)
+	require.Equal(t, "Something terrible has happened", report.Iterations[0].Tasks[1].Phases[1].Err.(*FatalError).msg)
+
+	opts = opts.WithErrorsToTolerate(1)
+	exec = New(&opts)
+	report, err = exec.RunWithResult(context.Background(), &FailOneTaskPlan{tasksLog: newTasksLog(), succeedAfter: 5})
+	require.True(t, IsFatal(err))
+	require.Equal(t, StopBudgetExhausted, report.Reason)
+	require.Equal(t, 2, report.BudgetErrors)
+	require.Len(t, report.Iterations, 2)
+
+	opts = opts.WithExecutionTimeout(100 * time.Millisecond)
+	exec = New(&opts)
+	report, err = exec.RunWithResult(context.Background(), &SleepyPlan{time.Second})
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Equal(t, StopTimeout, report.Reason)
+	require.Len(t, report.Iterations, 1)
+}
+
+func TestReasonOfWrappedContextErrors(t *testing.T) {
+	require.Equal(t, StopTimeout, ReasonOf(context.DeadlineExceeded))
+	require.Equal(t, StopCancelled, ReasonOf(context.Canceled))
+	require.Equal(t, StopFatal, ReasonOf(Fatal(fmt.Errorf("request failed: %w", context.DeadlineExceeded))))
+	require.Equal(t, StopFatal, ReasonOf(fmt.Errorf("list: %w", context.Canceled)))
+	budgetError := &FatalError{"Reached maximum number of errors to tolerate",
+		&BudgetExhaustedError{Err: fmt.Errorf("request failed: %w", context.DeadlineExceeded)}}
+	require.Equal(t, StopBudgetExhausted, ReasonOf(budgetError))
+}
@@ -0,0 +1,64 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+
+	"github.com/kouzant/execloop"
+)
+
+// RetryableTask overrides the RetryPolicy of the Options for a task. A zero
+// RetryPolicy keeps the one of the Options.
+type RetryableTask interface {
+	RetryPolicy() execloop.RetryPolicy
+}
+
+func (x *execution) retryPolicy(task Task) execloop.RetryPolicy {
+	if t, ok := implementation(task).(RetryableTask); ok && t.RetryPolicy() != (execloop.RetryPolicy{}) {
+		return t.RetryPolicy()
+	}
+	return x.options.RetryPolicy
+}
+
+// retry invokes a phase of a task until it succeeds or the retry policy of
+// the task is exhausted. It returns the error of the last attempt and the
+// number of attempts made.
+func (x *execution) retry(ctx context.Context, task Task, phase execloop.Phase, fn func() error) (int, error) {
+	policy := x.retryPolicy(task)
+	maxAttempts := policy.Attempts()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				x.options.Info("Phase succeeded after retrying", x.taskFields(task, execloop.F("phase", phase),
+					execloop.F("attempt", attempt), execloop.F("maxAttempts", maxAttempts))...)
+			}
+			return attempt, nil
+		}
+		if attempt >= maxAttempts || IsFatal(err) || IsPermanent(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+		delay := policy.Delay(attempt)
+		x.options.Warning("Phase failed, retrying", x.taskFields(task, execloop.F("phase", phase),
+			execloop.F("attempt", attempt), execloop.F("maxAttempts", maxAttempts), execloop.F("delay", delay),
+			execloop.F("error", err))...)
+		if sleep(ctx, delay) != nil {
+			return attempt, err
+		}
+	}
+}
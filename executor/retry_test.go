@@ -0,0 +1,121 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+type FlakyTask struct {
+	DummyTask
+	failures int
+	attempts int
+	policy   *execloop.RetryPolicy
+}
+
+func (f *FlakyTask) PerformAction() ([]Task, error) {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return nil, errors.New("A small tiny error")
+	}
+	return nil, nil
+}
+
+type RetryablePolicyTask struct {
+	FlakyTask
+}
+
+func (r *RetryablePolicyTask) RetryPolicy() execloop.RetryPolicy {
+	return *r.policy
+}
+
+func TestRetryInPlace(t *testing.T) {
+	task := &FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 2}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	opts := execloop.DefaultOptions().WithErrorsToTolerate(0).WithRetryPolicy(execloop.RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 10 * time.Millisecond,
+		Multiplier:   2,
+	})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 3, task.attempts)
+	require.Equal(t, 0, report.BudgetErrors)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	task := &FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 5}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 3})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 3, task.attempts)
+	require.Equal(t, 1, report.BudgetErrors)
+}
+
+func TestTaskRetryPolicyOverride(t *testing.T) {
+	task := &RetryablePolicyTask{FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 3,
+		policy: &execloop.RetryPolicy{MaxAttempts: 4}}}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2})
+	exec := New(&opts)
+	report, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 4, task.attempts)
+	require.Equal(t, 0, report.BudgetErrors)
+}
+
+func TestZeroTaskRetryPolicy(t *testing.T) {
+	task := &RetryablePolicyTask{FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 1,
+		policy: &execloop.RetryPolicy{}}}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2})
+	report, err := New(&opts).RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 2, task.attempts)
+	require.Equal(t, 0, report.BudgetErrors)
+
+	task = &RetryablePolicyTask{FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 1,
+		policy: &execloop.RetryPolicy{MaxAttempts: 1}}}
+	plan = &ConcurrentPlan{tasks: []Task{task}}
+	report, err = New(&opts).RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+	require.Equal(t, 1, task.attempts)
+	require.Equal(t, 1, report.BudgetErrors)
+}
+
+func TestFatalErrorIsNotRetried(t *testing.T) {
+	var tasksLog = [][]int{
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+	plan := &FailFatalPlan{tasksLog: tasksLog}
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 3})
+	exec := New(&opts)
+	err := exec.Run(plan)
+	require.True(t, errors.As(err, &fatalError))
+	require.Equal(t, 1, plan.tasksLog[1][Attempt])
+}
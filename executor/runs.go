@@ -0,0 +1,124 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// RunState tells what an active run is doing.
+type RunState string
+
+const (
+	RunRunning      RunState = "running"
+	RunWaiting      RunState = "waiting"
+	RunCompensating RunState = "compensating"
+)
+
+// RunStatus is a snapshot of an active run.
+type RunStatus struct {
+	ID        string    `json:"id"`
+	Started   time.Time `json:"started"`
+	State     RunState  `json:"state"`
+	Iteration int       `json:"iteration"`
+	Tasks     int       `json:"tasks"`
+	Errors    int       `json:"errors"`
+}
+
+// Runs returns the runs of the Executor which have not finished yet, the
+// oldest first.
+func (e *Executor) Runs() []RunStatus {
+	e.mu.Lock()
+	runs := make([]*execution, 0, len(e.runs))
+	for _, x := range e.runs {
+		runs = append(runs, x)
+	}
+	e.mu.Unlock()
+
+	statuses := make([]RunStatus, len(runs))
+	for i, x := range runs {
+		statuses[i] = x.status()
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Started.Before(statuses[j].Started)
+	})
+	return statuses
+}
+
+// start creates a new run and registers it as active. The returned context
+// is cancelled by Stop.
+func (e *Executor) start(ctx context.Context, id string) (*execution, context.Context, error) {
+	x := e.newExecution()
+	if id != "" {
+		x.id = id
+		x.report.ID = id
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.runs[x.id]; ok {
+		return nil, nil, fmt.Errorf("Run %s is already active", x.id)
+	}
+	ctx, x.cancel = context.WithCancel(ctx)
+	e.runs[x.id] = x
+	e.options.Debug("Run started", execloop.F("run", x.id))
+	return x, ctx, nil
+}
+
+func (e *Executor) done(x *execution) {
+	e.mu.Lock()
+	delete(e.runs, x.id)
+	e.mu.Unlock()
+	x.cancel()
+}
+
+// Stop stops every active run of the Executor. Reconcile returns nil while
+// the other runs return context.Canceled.
+func (e *Executor) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.options.Info("Stopping runs", execloop.F("runs", len(e.runs)))
+	for _, x := range e.runs {
+		x.cancel()
+	}
+}
+
+func (x *execution) setState(state RunState) {
+	x.mu.Lock()
+	x.state = state
+	x.mu.Unlock()
+}
+
+func (x *execution) status() RunStatus {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	status := RunStatus{
+		ID:      x.id,
+		Started: x.report.Started,
+		State:   x.state,
+		Tasks:   x.report.Tasks,
+		Errors:  x.report.Errors,
+	}
+	if x.iteration != nil {
+		status.Iteration = x.iteration.Number
+	}
+	return status
+}
@@ -0,0 +1,128 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+// BlockingTask fails once, then blocks in PerformAction until released.
+type BlockingTask struct {
+	DummyTask
+	started chan struct{}
+	release chan struct{}
+	failed  bool
+}
+
+func (b *BlockingTask) PerformAction() ([]Task, error) {
+	if !b.failed {
+		b.failed = true
+		return nil, errors.New("A small tiny error")
+	}
+	b.started <- struct{}{}
+	<-b.release
+	return nil, nil
+}
+
+// BlockingPlan returns its task until it has succeeded.
+type BlockingPlan struct {
+	task *BlockingTask
+	done bool
+}
+
+func (p *BlockingPlan) Create() ([]Task, error) {
+	if p.done {
+		return nil, nil
+	}
+	if p.task.failed {
+		p.done = true
+	}
+	return []Task{p.task}, nil
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	const runs = 8
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithErrorsToTolerate(1).
+		WithMaxConcurrency(2)
+	exec := New(&opts)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	reports := make([]*RunReport, runs)
+	errs := make([]error, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task := &BlockingTask{DummyTask: DummyTask{taskName: fmt.Sprintf("Task%d", i)}, started: started,
+				release: release}
+			reports[i], errs[i] = exec.RunWithResult(context.Background(), &BlockingPlan{task: task})
+		}(i)
+	}
+	for i := 0; i < runs; i++ {
+		<-started
+	}
+
+	statuses := exec.Runs()
+	require.Len(t, statuses, runs)
+	ids := make(map[string]bool)
+	for _, status := range statuses {
+		ids[status.ID] = true
+		require.Equal(t, RunRunning, status.State)
+		require.Equal(t, 2, status.Iteration)
+		require.Equal(t, 1, status.Errors)
+	}
+	require.Len(t, ids, runs)
+
+	close(release)
+	wg.Wait()
+	require.Empty(t, exec.Runs())
+	for i, report := range reports {
+		require.Nil(t, errs[i])
+		require.Equal(t, StopConverged, report.Reason)
+		require.Equal(t, 1, report.Errors)
+		require.Equal(t, 1, report.BudgetErrors)
+		require.Equal(t, 2, report.Tasks)
+	}
+}
+
+func TestResumeActiveRun(t *testing.T) {
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond)
+	exec := New(&opts)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	task := &BlockingTask{DummyTask: DummyTask{taskName: "Task0"}, started: started, release: release}
+	done := make(chan error)
+	go func() {
+		_, err := exec.Resume(context.Background(), "run-0", &BlockingPlan{task: task})
+		done <- err
+	}()
+	<-started
+	require.Equal(t, "run-0", exec.Runs()[0].ID)
+	_, err := exec.Resume(context.Background(), "run-0", &SuccessPlan{tasksLog: newTasksLog()})
+	require.Equal(t, "Run run-0 is already active", err.Error())
+	close(release)
+	require.Nil(t, <-done)
+}
@@ -0,0 +1,184 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// TimeoutTask overrides the timeouts of the Options for a task. A zero
+// duration keeps the timeout of the Options.
+type TimeoutTask interface {
+	TaskTimeout() time.Duration
+	PhaseTimeout(phase execloop.Phase) time.Duration
+}
+
+// TimeoutError is returned when a phase of a task does not finish within
+// its timeout or within the timeout of the whole task.
+type TimeoutError struct {
+	Task    string
+	Phase   execloop.Phase
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Task %s timed out after %s during %s", e.Task, e.Timeout, e.Phase)
+}
+
+func (x *execution) taskTimeout(task Task) time.Duration {
+	if t, ok := implementation(task).(TimeoutTask); ok && t.TaskTimeout() > 0 {
+		return t.TaskTimeout()
+	}
+	return x.options.TaskTimeout
+}
+
+func (x *execution) phaseTimeout(task Task, phase execloop.Phase) time.Duration {
+	if t, ok := implementation(task).(TimeoutTask); ok && t.PhaseTimeout(phase) > 0 {
+		return t.PhaseTimeout(phase)
+	}
+	switch phase {
+	case execloop.PhasePre:
+		return x.options.PreTimeout
+	case execloop.PhasePerformAction:
+		return x.options.ActionTimeout
+	case execloop.PhasePost:
+		return x.options.PostTimeout
+	}
+	return 0
+}
+
+// taskDeadline bounds the phases of a task, zero when there is no
+// TaskTimeout.
+type taskDeadline struct {
+	deadline time.Time
+	timeout  time.Duration
+}
+
+func (x *execution) newTaskDeadline(task Task) taskDeadline {
+	timeout := x.taskTimeout(task)
+	if timeout <= 0 {
+		return taskDeadline{}
+	}
+	return taskDeadline{time.Now().Add(timeout), timeout}
+}
+
+// withTimeout runs an attempt of a phase with the earliest of its timeout and
+// the deadline of the task. The context of the attempt is cancelled when it
+// times out, and tasks which ignore it are abandoned in the background,
+// holding the slot of the worker pool until they return. A task never runs
+// concurrently with its abandoned attempt: the next attempt waits for it to
+// return, within its own timeout.
+func (x *execution) withTimeout(ctx context.Context, task Task, phase execloop.Phase, deadline taskDeadline,
+	slot *workerSlot, fn func(context.Context) ([]Task, error)) ([]Task, error) {
+	previous := x.abandonedAttempt(task)
+	timeout := x.phaseTimeout(task, phase)
+	exceeded := timeout
+	if !deadline.deadline.IsZero() {
+		if remaining := time.Until(deadline.deadline); timeout <= 0 || remaining < timeout {
+			timeout = remaining
+			exceeded = deadline.timeout
+		}
+	}
+	if exceeded <= 0 {
+		if previous != nil {
+			select {
+			case <-previous:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return fn(ctx)
+	}
+	timeoutError := &TimeoutError{Task: task.Name(), Phase: phase, Timeout: exceeded}
+	if timeout <= 0 {
+		return nil, x.timedOut(task, timeoutError)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		children []Task
+		err      error
+	}
+	done := make(chan result, 1)
+	returned := make(chan struct{})
+	slot.hold()
+	go func() {
+		defer slot.release()
+		defer close(returned)
+		if previous != nil {
+			<-previous
+			if attemptCtx.Err() != nil {
+				done <- result{err: attemptCtx.Err()}
+				return
+			}
+		}
+		children, err := fn(attemptCtx)
+		done <- result{children, err}
+	}()
+	var r result
+	abandoned := false
+	select {
+	case r = <-done:
+	case <-attemptCtx.Done():
+		r.err = attemptCtx.Err()
+		abandoned = true
+		x.abandon(task, returned)
+	}
+	if (abandoned || r.err != nil) && ctx.Err() == nil && attemptCtx.Err() == context.DeadlineExceeded {
+		return nil, x.timedOut(task, timeoutError)
+	}
+	return r.children, r.err
+}
+
+// abandonedAttempt returns the channel closed when the abandoned attempt of
+// a task returns, nil when there is none still running.
+func (x *execution) abandonedAttempt(task Task) chan struct{} {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	returned, ok := x.abandoned[task.Name()]
+	if !ok {
+		return nil
+	}
+	select {
+	case <-returned:
+		delete(x.abandoned, task.Name())
+		return nil
+	default:
+		return returned
+	}
+}
+
+// abandon records an attempt which timed out. An attempt returns only after
+// the attempt it waited for, so it replaces it.
+func (x *execution) abandon(task Task, returned chan struct{}) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.abandoned[task.Name()] = returned
+}
+
+func (x *execution) timedOut(task Task, err *TimeoutError) error {
+	x.options.Warning("Task timed out", x.taskFields(task, execloop.F("phase", err.Phase),
+		execloop.F("timeout", err.Timeout))...)
+	if x.options.TimeoutsAreFatal {
+		return Fatal(err)
+	}
+	return err
+}
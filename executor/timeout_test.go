@@ -0,0 +1,198 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+// HungTask blocks in PerformAction ignoring any context.
+type HungTask struct {
+	DummyTask
+	release  chan struct{}
+	timeouts map[execloop.Phase]time.Duration
+}
+
+func (h *HungTask) PerformAction() ([]Task, error) {
+	<-h.release
+	return nil, nil
+}
+
+func (h *HungTask) TaskTimeout() time.Duration {
+	return 0
+}
+
+func (h *HungTask) PhaseTimeout(phase execloop.Phase) time.Duration {
+	return h.timeouts[phase]
+}
+
+func TestActionTimeoutAbandonsTask(t *testing.T) {
+	task := &HungTask{DummyTask: DummyTask{taskName: "hung"}, release: make(chan struct{})}
+	defer close(task.release)
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithActionTimeout(50 * time.Millisecond)
+	started := time.Now()
+	report, err := New(&opts).RunWithResult(context.Background(), &ConcurrentPlan{tasks: []Task{task}})
+	require.Nil(t, err)
+	require.True(t, time.Since(started) < time.Second)
+	require.Equal(t, 1, report.Errors)
+	phases := report.Iterations[0].Tasks[0].Phases
+	require.Len(t, phases, 2)
+	var timeoutError *TimeoutError
+	require.True(t, errors.As(phases[1].Err, &timeoutError))
+	require.Equal(t, &TimeoutError{Task: "hung", Phase: execloop.PhasePerformAction, Timeout: 50 * time.Millisecond},
+		timeoutError)
+}
+
+func TestTimeoutTaskOverride(t *testing.T) {
+	task := &HungTask{DummyTask: DummyTask{taskName: "hung"}, release: make(chan struct{}),
+		timeouts: map[execloop.Phase]time.Duration{execloop.PhasePerformAction: 20 * time.Millisecond}}
+	defer close(task.release)
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithActionTimeout(time.Hour).
+		WithTimeoutsAreFatal(true)
+	err := New(&opts).RunWithContext(context.Background(), &ConcurrentPlan{tasks: []Task{task}})
+	require.True(t, IsFatal(err))
+	var timeoutError *TimeoutError
+	require.True(t, errors.As(err, &timeoutError))
+	require.Equal(t, 20*time.Millisecond, timeoutError.Timeout)
+}
+
+func TestTaskTimeoutCancelsContext(t *testing.T) {
+	task := &CancellableTask{DummyTask: DummyTask{taskName: "cancellable", tasksLog: newTasksLog()},
+		started: make(chan struct{}), cancelled: make(chan struct{})}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithTaskTimeout(50 * time.Millisecond)
+	report, err := New(&opts).RunWithResult(context.Background(), &ConcurrentPlan{tasks: []Task{FromContextTask(task)}})
+	require.Nil(t, err)
+	<-task.cancelled
+	require.Equal(t, 0, task.tasksLog[0][Post])
+	require.Equal(t, StopConverged, report.Reason)
+	var timeoutError *TimeoutError
+	require.True(t, errors.As(report.Iterations[0].Tasks[0].Phases[1].Err, &timeoutError))
+	require.Equal(t, 50*time.Millisecond, timeoutError.Timeout)
+}
+
+// SlowOnceTask ignores its timeout in the first attempt of PerformAction.
+type SlowOnceTask struct {
+	DummyTask
+	attempts int32
+	started  chan time.Time
+}
+
+func (s *SlowOnceTask) PerformAction() ([]Task, error) {
+	if s.started != nil {
+		s.started <- time.Now()
+	}
+	if atomic.AddInt32(&s.attempts, 1) == 1 {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil, nil
+}
+
+func TestTimeoutAppliesToEachAttempt(t *testing.T) {
+	task := &SlowOnceTask{DummyTask: DummyTask{taskName: "slow"}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithActionTimeout(50 * time.Millisecond).
+		WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 2, InitialDelay: 200 * time.Millisecond})
+	report, err := New(&opts).RunWithResult(context.Background(), &ConcurrentPlan{tasks: []Task{task}})
+	require.Nil(t, err)
+	require.Equal(t, 0, report.Errors)
+	phase := report.Iterations[0].Tasks[0].Phases[1]
+	require.Nil(t, phase.Err)
+	require.Equal(t, 2, phase.Attempts)
+}
+
+func TestAbandonedPhaseHoldsWorker(t *testing.T) {
+	var tasks []Task
+	for _, name := range []string{"slow0", "slow1", "next"} {
+		tasks = append(tasks, &SlowOnceTask{DummyTask: DummyTask{taskName: name}, started: make(chan time.Time, 1)})
+	}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithActionTimeout(20 * time.Millisecond).
+		WithMaxConcurrency(2)
+	started := time.Now()
+	report, err := New(&opts).RunWithResult(context.Background(), &ConcurrentPlan{tasks: tasks})
+	require.Nil(t, err)
+	require.Equal(t, 3, report.Errors)
+	// at most two tasks run at the same time, including the abandoned ones
+	var starts []time.Duration
+	for _, task := range tasks {
+		starts = append(starts, (<-task.(*SlowOnceTask).started).Sub(started))
+	}
+	late := 0
+	for _, start := range starts {
+		if start >= 150*time.Millisecond {
+			late++
+		}
+	}
+	require.Equal(t, 1, late, "%v", starts)
+}
+
+// OverlappingTask hangs in PerformAction ignoring any context and records
+// how many of its attempts run at the same time.
+type OverlappingTask struct {
+	DummyTask
+	attempts   int32
+	running    int32
+	maxRunning int32
+}
+
+func (o *OverlappingTask) PerformAction() ([]Task, error) {
+	atomic.AddInt32(&o.attempts, 1)
+	running := atomic.AddInt32(&o.running, 1)
+	defer atomic.AddInt32(&o.running, -1)
+	for {
+		max := atomic.LoadInt32(&o.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&o.maxRunning, max, running) {
+			break
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	return nil, nil
+}
+
+// RepeatingPlan returns the same tasks for a number of iterations.
+type RepeatingPlan struct {
+	tasks      []Task
+	iterations int
+}
+
+func (p *RepeatingPlan) Create() ([]Task, error) {
+	if p.iterations == 0 {
+		return nil, nil
+	}
+	p.iterations--
+	return p.tasks, nil
+}
+
+func TestAbandonedAttemptIsNotRunConcurrently(t *testing.T) {
+	task := &OverlappingTask{DummyTask: DummyTask{taskName: "hung"}}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithActionTimeout(20 * time.Millisecond).
+		WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 3}).WithMaxConcurrency(2)
+	report, err := New(&opts).RunWithResult(context.Background(), &RepeatingPlan{tasks: []Task{task}, iterations: 3})
+	require.Nil(t, err)
+	require.Equal(t, 3, report.Errors)
+	require.Equal(t, 3, report.Iterations[0].Tasks[0].Phases[1].Attempts)
+	// the attempts waiting for an abandoned one time out without running
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, atomic.LoadInt32(&task.attempts) > 1)
+	require.True(t, atomic.LoadInt32(&task.attempts) < 9)
+	require.Equal(t, int32(1), atomic.LoadInt32(&task.maxRunning))
+}
@@ -0,0 +1,141 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+const (
+	AttributeIteration = "execloop.iteration"
+	AttributeTask      = "execloop.task"
+	AttributePhase     = "execloop.phase"
+	AttributeAttempts  = "execloop.attempts"
+	AttributeError     = "execloop.error"
+)
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...execloop.Attribute) {}
+func (noopSpan) RecordError(err error)                          {}
+func (noopSpan) End()                                           {}
+
+func (x *execution) startSpan(ctx context.Context, name string, attributes ...execloop.Attribute) (context.Context,
+	execloop.Span) {
+	if x.options.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return x.options.Tracer.Start(ctx, name, attributes...)
+}
+
+// endSpan records err, if any, and ends the span.
+func endSpan(span execloop.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// InMemoryTracer is a Tracer which keeps every span in memory. It is meant
+// for tests and debugging.
+type InMemoryTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Children   []*RecordedSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Started    time.Time
+	Ended      time.Time
+	tracer     *InMemoryTracer
+}
+
+type spanKey struct{}
+
+func NewInMemoryTracer() *InMemoryTracer {
+	return &InMemoryTracer{}
+}
+
+func (t *InMemoryTracer) Start(ctx context.Context, name string, attributes ...execloop.Attribute) (context.Context,
+	execloop.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &RecordedSpan{
+		Name:       name,
+		Attributes: make(map[string]interface{}),
+		Started:    time.Now(),
+		tracer:     t,
+	}
+	for _, attribute := range attributes {
+		span.Attributes[attribute.Key] = attribute.Value
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok && parent.tracer == t {
+		span.Parent = parent
+		parent.Children = append(parent.Children, span)
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Spans returns every span started so far in the order they were started.
+func (t *InMemoryTracer) Spans() []*RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*RecordedSpan(nil), t.spans...)
+}
+
+// Roots returns the spans without a parent.
+func (t *InMemoryTracer) Roots() []*RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var roots []*RecordedSpan
+	for _, span := range t.spans {
+		if span.Parent == nil {
+			roots = append(roots, span)
+		}
+	}
+	return roots
+}
+
+func (s *RecordedSpan) SetAttributes(attributes ...execloop.Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, attribute := range attributes {
+		s.Attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+	s.Attributes[AttributeError] = err.Error()
+}
+
+func (s *RecordedSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Ended = time.Now()
+}
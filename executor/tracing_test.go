@@ -0,0 +1,95 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+func spanTree(span *RecordedSpan, depth int) []string {
+	name := span.Name
+	if task, ok := span.Attributes[AttributeTask]; ok && span.Name == "task" {
+		name += " " + task.(string)
+	}
+	lines := []string{strings.Repeat("  ", depth) + name}
+	for _, child := range span.Children {
+		lines = append(lines, spanTree(child, depth+1)...)
+	}
+	return lines
+}
+
+func TestTracing(t *testing.T) {
+	plan := &ChildrenPlan{tasksLog: newTasksLog()}
+	tracer := NewInMemoryTracer()
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Millisecond).WithTracer(tracer)
+	exec := New(&opts)
+	require.Nil(t, exec.Run(plan))
+
+	roots := tracer.Roots()
+	require.Len(t, roots, 1)
+	require.Equal(t, []string{
+		"run",
+		"  iteration",
+		"    task ParentTask0",
+		"      Pre",
+		"      PerformAction",
+		"      Post",
+		"      Children",
+		"        task KidTask0",
+		"          Pre",
+		"          PerformAction",
+		"          Post",
+		"        task KidTask1",
+		"          Pre",
+		"          PerformAction",
+		"          Post",
+		"  iteration",
+	}, spanTree(roots[0], 0))
+
+	for _, span := range tracer.Spans() {
+		require.False(t, span.Ended.IsZero())
+	}
+	require.Equal(t, 2, roots[0].Children[1].Attributes[AttributeIteration])
+}
+
+func TestTracingErrorsAndAttempts(t *testing.T) {
+	task := &FlakyTask{DummyTask: DummyTask{taskName: "FlakyTask"}, failures: 5}
+	plan := &ConcurrentPlan{tasks: []Task{task}}
+	tracer := NewInMemoryTracer()
+	opts := execloop.DefaultOptions().WithRetryPolicy(execloop.RetryPolicy{MaxAttempts: 3}).WithTracer(tracer)
+	exec := New(&opts)
+	_, err := exec.RunWithResult(context.Background(), plan)
+	require.Nil(t, err)
+
+	var performAction *RecordedSpan
+	for _, span := range tracer.Spans() {
+		if span.Name == string(execloop.PhasePerformAction) {
+			performAction = span
+		}
+	}
+	require.NotNil(t, performAction)
+	require.Equal(t, "FlakyTask", performAction.Attributes[AttributeTask])
+	require.Equal(t, 3, performAction.Attributes[AttributeAttempts])
+	require.Equal(t, "A small tiny error", performAction.Attributes[AttributeError])
+	require.Len(t, performAction.Errors, 1)
+}
@@ -0,0 +1,58 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// Trigger asks every active run to create its plan again right away instead
+// of waiting for the next interval. Triggers arriving while an iteration is
+// executing are coalesced into a single re-plan after it.
+func (e *Executor) Trigger() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.options.Debug("Triggering runs", execloop.F("runs", len(e.runs)))
+	for _, x := range e.runs {
+		select {
+		case x.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wait sleeps for the interval, or until a trigger when WaitForTrigger is
+// set, returning early when the run is triggered.
+func (x *execution) wait(ctx context.Context, interval time.Duration) error {
+	var timeout <-chan time.Time
+	if !x.options.WaitForTrigger {
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+	case <-x.trigger:
+		x.options.Debug("Run triggered", execloop.F("run", x.id))
+	}
+	return nil
+}
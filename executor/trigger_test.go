@@ -0,0 +1,89 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+// CountingPlan returns a task in its first iterations.
+type CountingPlan struct {
+	mu         sync.Mutex
+	iterations int
+	creates    int
+}
+
+func (p *CountingPlan) Create() ([]Task, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.creates++
+	if p.creates > p.iterations {
+		return nil, nil
+	}
+	return []Task{&DummyTask{taskName: "DummyTask"}}, nil
+}
+
+func (p *CountingPlan) Creates() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.creates
+}
+
+func waitForState(t *testing.T, exec *Executor, state RunState) {
+	require.Eventually(t, func() bool {
+		runs := exec.Runs()
+		return len(runs) == 1 && runs[0].State == state
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestTriggerInterruptsInterval(t *testing.T) {
+	plan := &CountingPlan{iterations: 2}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(time.Hour)
+	exec := New(&opts)
+	done := make(chan error)
+	go func() {
+		done <- exec.RunWithContext(context.Background(), plan)
+	}()
+	for i := 0; i < 2; i++ {
+		waitForState(t, exec, RunWaiting)
+		exec.Trigger()
+	}
+	require.Nil(t, <-done)
+	require.Equal(t, 3, plan.Creates())
+}
+
+func TestWaitForTrigger(t *testing.T) {
+	plan := &CountingPlan{iterations: 1}
+	opts := execloop.DefaultOptions().WithSleepBetweenRuns(0).WithWaitForTrigger(true)
+	exec := New(&opts)
+	done := make(chan error)
+	go func() {
+		done <- exec.RunWithContext(context.Background(), plan)
+	}()
+	waitForState(t, exec, RunWaiting)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, plan.Creates())
+	exec.Trigger()
+	require.Nil(t, <-done)
+	require.Equal(t, 2, plan.Creates())
+}
@@ -0,0 +1,87 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+import (
+	"math/rand"
+	"time"
+)
+
+// IntervalStrategy decides how long the executor waits between two
+// iterations of a plan. clean is true when every task of the iteration
+// succeeded, progressed is false when the plan returned the same tasks as in
+// the previous iteration. previous is the interval returned for the last
+// iteration or zero for the first one.
+type IntervalStrategy interface {
+	Next(previous time.Duration, clean, progressed bool) time.Duration
+}
+
+type ConstantInterval struct {
+	Interval time.Duration
+}
+
+func (c ConstantInterval) Next(previous time.Duration, clean, progressed bool) time.Duration {
+	return c.Interval
+}
+
+// ExponentialInterval multiplies the interval after every iteration with
+// errors or without progress and resets it to Min after a clean one which
+// made progress.
+type ExponentialInterval struct {
+	Min        time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (e ExponentialInterval) Next(previous time.Duration, clean, progressed bool) time.Duration {
+	if (clean && progressed) || previous < e.Min {
+		return e.Min
+	}
+	multiplier := e.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	next := time.Duration(float64(previous) * multiplier)
+	if e.Max > 0 && next > e.Max {
+		return e.Max
+	}
+	return next
+}
+
+// DecorrelatedJitterInterval picks a random interval between Min and three
+// times the previous one after every iteration with errors or without
+// progress and resets it to Min after a clean one which made progress.
+type DecorrelatedJitterInterval struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (d DecorrelatedJitterInterval) Next(previous time.Duration, clean, progressed bool) time.Duration {
+	if (clean && progressed) || previous < d.Min {
+		return d.Min
+	}
+	upper := 3 * previous
+	next := d.Min
+	if upper > d.Min {
+		next += time.Duration(rand.Int63n(int64(upper - d.Min)))
+	}
+	if d.Max > 0 && next > d.Max {
+		return d.Max
+	}
+	return next
+}
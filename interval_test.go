@@ -0,0 +1,61 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package execloop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantInterval(t *testing.T) {
+	strategy := ConstantInterval{time.Second}
+	require.Equal(t, time.Second, strategy.Next(0, false, true))
+	require.Equal(t, time.Second, strategy.Next(time.Minute, true, true))
+}
+
+func TestExponentialInterval(t *testing.T) {
+	strategy := ExponentialInterval{Min: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	require.Equal(t, time.Second, strategy.Next(0, false, true))
+	require.Equal(t, 2*time.Second, strategy.Next(time.Second, false, true))
+	require.Equal(t, 4*time.Second, strategy.Next(2*time.Second, false, true))
+	require.Equal(t, 5*time.Second, strategy.Next(4*time.Second, false, true))
+	require.Equal(t, time.Second, strategy.Next(5*time.Second, true, true))
+	require.Equal(t, 4*time.Second, strategy.Next(2*time.Second, true, false))
+}
+
+func TestDecorrelatedJitterInterval(t *testing.T) {
+	strategy := DecorrelatedJitterInterval{Min: time.Second, Max: 10 * time.Second}
+	require.Equal(t, time.Second, strategy.Next(0, false, true))
+	previous := time.Second
+	for i := 0; i < 100; i++ {
+		next := strategy.Next(previous, false, true)
+		require.True(t, next >= time.Second && next <= 10*time.Second)
+		require.True(t, next <= 3*previous)
+		previous = next
+	}
+	require.Equal(t, time.Second, strategy.Next(previous, true, true))
+}
+
+func TestNextIntervalDefaultsToSleepBetweenRuns(t *testing.T) {
+	opts := DefaultOptions().WithSleepBetweenRuns(3 * time.Second)
+	require.Equal(t, 3*time.Second, opts.NextInterval(time.Minute, false, true))
+
+	opts = opts.WithIntervalStrategy(ConstantInterval{time.Millisecond})
+	require.Equal(t, time.Millisecond, opts.NextInterval(time.Minute, false, true))
+}
@@ -17,8 +17,15 @@ along with execloop.  If not, see <https://www.gnu.org/licenses/>.
 package execloop
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Logger interface {
@@ -28,32 +35,225 @@ type Logger interface {
 	Errorf(string, ...interface{})
 }
 
-func (o *Options) Debugf(f string, v ...interface{}) {
-	if o.Logger == nil {
-		return
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarning:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
 	}
-	o.Logger.Debugf(f, v...)
 }
 
-func (o *Options) Infof(f string, v ...interface{}) {
-	if o.Logger == nil {
-		return
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarning, nil
+	case "ERROR":
+		return LevelError, nil
+	default:
+		return LevelDebug, fmt.Errorf("unknown log level %q", level)
 	}
-	o.Logger.Infof(f, v...)
+}
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{key, value}
+}
+
+// StructuredLogger logs a message along with key/value fields.
+type StructuredLogger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+func (o *Options) Debugf(f string, v ...interface{}) {
+	o.logf(LevelDebug, f, v...)
+}
+
+func (o *Options) Infof(f string, v ...interface{}) {
+	o.logf(LevelInfo, f, v...)
 }
 
 func (o *Options) Warningf(f string, v ...interface{}) {
+	o.logf(LevelWarning, f, v...)
+}
+
+func (o *Options) Errorf(f string, v ...interface{}) {
+	o.logf(LevelError, f, v...)
+}
+
+func (o *Options) logf(level Level, f string, v ...interface{}) {
+	if level < o.LogLevel {
+		return
+	}
+	if o.StructuredLogger != nil {
+		o.StructuredLogger.Log(level, strings.TrimSuffix(fmt.Sprintf(f, v...), "\n"))
+		return
+	}
 	if o.Logger == nil {
 		return
 	}
-	o.Logger.Warningf(f, v...)
+	printf(o.Logger, level)(f, v...)
 }
 
-func (o *Options) Errorf(f string, v ...interface{}) {
+func (o *Options) Debug(msg string, fields ...Field) {
+	o.Log(LevelDebug, msg, fields...)
+}
+
+func (o *Options) Info(msg string, fields ...Field) {
+	o.Log(LevelInfo, msg, fields...)
+}
+
+func (o *Options) Warning(msg string, fields ...Field) {
+	o.Log(LevelWarning, msg, fields...)
+}
+
+func (o *Options) Error(msg string, fields ...Field) {
+	o.Log(LevelError, msg, fields...)
+}
+
+// Log logs to the StructuredLogger or, if there is none, to the Logger of
+// the options, dropping messages below LogLevel.
+func (o *Options) Log(level Level, msg string, fields ...Field) {
+	if level < o.LogLevel {
+		return
+	}
+	if o.StructuredLogger != nil {
+		o.StructuredLogger.Log(level, msg, fields...)
+		return
+	}
 	if o.Logger == nil {
 		return
 	}
-	o.Logger.Errorf(f, v...)
+	FromLogger(o.Logger).Log(level, msg, fields...)
+}
+
+func printf(logger Logger, level Level) func(string, ...interface{}) {
+	switch level {
+	case LevelDebug:
+		return logger.Debugf
+	case LevelInfo:
+		return logger.Infof
+	case LevelWarning:
+		return logger.Warningf
+	default:
+		return logger.Errorf
+	}
+}
+
+type printfLogger struct {
+	logger Logger
+}
+
+// FromLogger adapts a printf Logger to a StructuredLogger. Fields are
+// appended to the message as key=value pairs.
+func FromLogger(logger Logger) StructuredLogger {
+	return &printfLogger{logger}
+}
+
+func (p *printfLogger) Log(level Level, msg string, fields ...Field) {
+	printf(p.logger, level)("%s\n", formatText(msg, fields))
+}
+
+func formatText(msg string, fields []Field) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&b, " %s=%s", field.Key, value)
+	}
+	return b.String()
+}
+
+type textLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTextLogger logs lines of the form "time LEVEL msg key=value".
+func NewTextLogger(w io.Writer) StructuredLogger {
+	return &textLogger{w: w}
+}
+
+func (t *textLogger) Log(level Level, msg string, fields ...Field) {
+	line := fmt.Sprintf("%s %s %s\n", time.Now().Format(time.RFC3339), level, formatText(msg, fields))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, line)
+}
+
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLogger logs one JSON object per line with the time, level, msg
+// and every field as a key.
+func NewJSONLogger(w io.Writer) StructuredLogger {
+	return &jsonLogger{w: w}
+}
+
+func (j *jsonLogger) Log(level Level, msg string, fields ...Field) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	writeJSONField(&b, "time", time.Now().Format(time.RFC3339Nano))
+	b.WriteString(",")
+	writeJSONField(&b, "level", level.String())
+	b.WriteString(",")
+	writeJSONField(&b, "msg", msg)
+	for _, field := range fields {
+		b.WriteString(",")
+		writeJSONField(&b, field.Key, field.Value)
+	}
+	b.WriteString("}\n")
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.w.Write(b.Bytes())
+}
+
+func writeJSONField(b *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteString(":")
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(encoded)
 }
 
 type defaultLogger struct {
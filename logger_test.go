@@ -17,8 +17,13 @@ along with execloop.  If not, see <https://www.gnu.org/licenses/>.
 package execloop
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -59,3 +64,67 @@ func TestLog(t *testing.T) {
 	opts.Errorf("test")
 	require.Equal(t, "ERROR: test", logger.output)
 }
+
+func TestLogLevel(t *testing.T) {
+	logger := &mockLogger{}
+	opts := Options{}.WithLogger(logger).WithLogLevel(LevelWarning)
+
+	opts.Infof("test")
+	opts.Debug("test")
+	require.Equal(t, "", logger.output)
+
+	opts.Warningf("test")
+	require.Equal(t, "WARN: test", logger.output)
+
+	opts.Error("test", F("task", "vm0"))
+	require.Equal(t, "ERROR: test task=vm0\n", logger.output)
+}
+
+func TestPrintfShim(t *testing.T) {
+	logger := &mockLogger{}
+	opts := Options{}.WithLogger(logger)
+
+	opts.Info("Executing task", F("task", "vm0"), F("iteration", 2), F("error", errors.New("a b")))
+	require.Equal(t, "INFO: Executing task task=vm0 iteration=2 error=\"a b\"\n", logger.output)
+}
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+	opts := Options{}.WithStructuredLogger(NewJSONLogger(&out)).WithLogLevel(LevelInfo)
+
+	opts.Debug("dropped")
+	opts.Warning("Task failed", F("task", "vm0"), F("attempt", 2), F("error", errors.New("boom")),
+		F("phase", PhasePost), F("delay", time.Second))
+	opts.Infof("printf %d", 1)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "WARN", entry["level"])
+	require.Equal(t, "Task failed", entry["msg"])
+	require.Equal(t, "vm0", entry["task"])
+	require.Equal(t, float64(2), entry["attempt"])
+	require.Equal(t, "boom", entry["error"])
+	require.Equal(t, "Post", entry["phase"])
+	require.Equal(t, "1s", entry["delay"])
+	require.NotEmpty(t, entry["time"])
+
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.Equal(t, "printf 1", entry["msg"])
+}
+
+func TestTextLogger(t *testing.T) {
+	var out bytes.Buffer
+	opts := Options{}.WithStructuredLogger(NewTextLogger(&out))
+	opts.Info("Executing task", F("task", "vm0"))
+	require.True(t, strings.HasSuffix(out.String(), " INFO Executing task task=vm0\n"))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warning")
+	require.Nil(t, err)
+	require.Equal(t, LevelWarning, level)
+	_, err = ParseLevel("loud")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,270 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+)
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics is an execloop.Observer which records the execution of plans and
+// exposes them in the Prometheus text exposition format.
+type Metrics struct {
+	mu          sync.Mutex
+	buckets     []float64
+	iterations  float64
+	planCreate  *histogram
+	phases      map[phaseKey]*histogram
+	tolerated   map[string]float64
+	runs        map[string]float64
+	fatalErrors float64
+	converged   float64
+}
+
+type phaseKey struct {
+	task   string
+	phase  execloop.Phase
+	result string
+}
+
+type histogram struct {
+	counts []float64
+	sum    float64
+	count  float64
+}
+
+func New() *Metrics {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets creates Metrics whose histograms use the given upper
+// bounds, in seconds, for their buckets.
+func NewWithBuckets(buckets []float64) *Metrics {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Metrics{
+		buckets:    b,
+		planCreate: newHistogram(b),
+		phases:     make(map[phaseKey]*histogram),
+		tolerated:  make(map[string]float64),
+		runs:       make(map[string]float64),
+	}
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{counts: make([]float64, len(buckets))}
+}
+
+func (h *histogram) observe(buckets []float64, d time.Duration) {
+	v := d.Seconds()
+	for i, upper := range buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (m *Metrics) OnIterationStart(iteration int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.iterations++
+}
+
+func (m *Metrics) OnPlanCreated(iteration int, tasks []string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.planCreate.observe(m.buckets, duration)
+}
+
+func (m *Metrics) OnTaskPhaseStart(task string, phase execloop.Phase) {}
+
+func (m *Metrics) OnTaskPhaseEnd(task string, phase execloop.Phase, err error, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := phaseKey{task, phase, "success"}
+	if err != nil {
+		key.result = "error"
+	}
+	h, ok := m.phases[key]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.phases[key] = h
+	}
+	h.observe(m.buckets, duration)
+}
+
+func (m *Metrics) OnChildrenScheduled(task string, children []string) {}
+
+func (m *Metrics) OnErrorTolerated(task string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tolerated[task]++
+}
+
+func (m *Metrics) OnRunFinished(err error, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reason := executor.ReasonOf(err)
+	m.runs[string(reason)]++
+	if reason == executor.StopFatal {
+		m.fatalErrors++
+	}
+}
+
+func (m *Metrics) OnConverged(iteration int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.converged++
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := m.WriteTo(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	cw.header("execloop_iterations_total", "counter", "Number of plan iterations started.")
+	cw.sample("execloop_iterations_total", nil, m.iterations)
+
+	cw.header("execloop_plan_create_duration_seconds", "histogram", "Latency of Plan.Create.")
+	cw.histogram("execloop_plan_create_duration_seconds", nil, m.buckets, m.planCreate)
+
+	cw.header("execloop_task_phase_duration_seconds", "histogram", "Duration of the phases of the tasks.")
+	phaseKeys := make([]phaseKey, 0, len(m.phases))
+	for key := range m.phases {
+		phaseKeys = append(phaseKeys, key)
+	}
+	sort.Slice(phaseKeys, func(i, j int) bool {
+		a, b := phaseKeys[i], phaseKeys[j]
+		if a.task != b.task {
+			return a.task < b.task
+		}
+		if a.phase != b.phase {
+			return a.phase < b.phase
+		}
+		return a.result < b.result
+	})
+	for _, key := range phaseKeys {
+		labels := []string{"task", key.task, "phase", string(key.phase), "result", key.result}
+		cw.histogram("execloop_task_phase_duration_seconds", labels, m.buckets, m.phases[key])
+	}
+
+	cw.header("execloop_task_errors_tolerated_total", "counter", "Number of task errors which did not stop the run.")
+	for _, task := range sortedKeys(m.tolerated) {
+		cw.sample("execloop_task_errors_tolerated_total", []string{"task", task}, m.tolerated[task])
+	}
+
+	cw.header("execloop_runs_total", "counter", "Number of finished runs by the reason they stopped.")
+	for _, result := range sortedKeys(m.runs) {
+		cw.sample("execloop_runs_total", []string{"outcome", result}, m.runs[result])
+	}
+
+	cw.header("execloop_fatal_exits_total", "counter", "Number of runs stopped by a FatalError.")
+	cw.sample("execloop_fatal_exits_total", nil, m.fatalErrors)
+
+	cw.header("execloop_converged_total", "counter", "Number of times a reconciled plan reached its final state.")
+	cw.sample("execloop_converged_total", nil, m.converged)
+
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, a ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, a...)
+	c.n += int64(n)
+	c.err = err
+}
+
+func (c *countingWriter) header(name, kind, help string) {
+	c.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (c *countingWriter) sample(name string, labels []string, value float64) {
+	c.printf("%s%s %s\n", name, formatLabels(labels), formatFloat(value))
+}
+
+func (c *countingWriter) histogram(name string, labels []string, buckets []float64, h *histogram) {
+	for i, upper := range buckets {
+		c.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", formatFloat(upper)), h.counts[i])
+	}
+	c.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", "+Inf"), h.count)
+	c.sample(name+"_sum", labels, h.sum)
+	c.sample(name+"_count", labels, h.count)
+}
+
+// formatLabels formats pairs of label names and values.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escape(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
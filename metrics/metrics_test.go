@@ -0,0 +1,116 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+	"github.com/stretchr/testify/require"
+)
+
+type task struct {
+	name string
+	err  error
+}
+
+func (t *task) Pre() error {
+	return nil
+}
+
+func (t *task) PerformAction() ([]executor.Task, error) {
+	return nil, t.err
+}
+
+func (t *task) Post() error {
+	return nil
+}
+
+func (t *task) Name() string {
+	return t.name
+}
+
+type plan struct {
+	tasks []executor.Task
+	done  bool
+}
+
+func (p *plan) Create() ([]executor.Task, error) {
+	if p.done {
+		return nil, nil
+	}
+	p.done = true
+	return p.tasks, nil
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewWithBuckets([]float64{1, 0.5})
+	opts := execloop.DefaultOptions().WithLogger(nil).WithSleepBetweenRuns(time.Millisecond).WithObserver(m)
+	exec := executor.New(&opts)
+	require.Nil(t, exec.Run(&plan{tasks: []executor.Task{
+		&task{name: "vm0"},
+		&task{name: "vm\"1", err: errors.New("boom")},
+	}}))
+	require.True(t, executor.IsFatal(exec.Run(&plan{tasks: []executor.Task{
+		&task{name: "vm2", err: executor.Fatalf("boom")},
+	}})))
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	require.Nil(t, err)
+	defer response.Body.Close()
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", response.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(response.Body)
+	require.Nil(t, err)
+	output := string(body)
+
+	require.Contains(t, output, "# TYPE execloop_iterations_total counter\nexecloop_iterations_total 3\n")
+	require.Contains(t, output, "execloop_plan_create_duration_seconds_bucket{le=\"0.5\"} 3\n")
+	require.Contains(t, output, "execloop_plan_create_duration_seconds_bucket{le=\"+Inf\"} 3\n")
+	require.Contains(t, output, "execloop_plan_create_duration_seconds_count 3\n")
+	require.Contains(t, output,
+		"execloop_task_phase_duration_seconds_count{task=\"vm0\",phase=\"PerformAction\",result=\"success\"} 1\n")
+	require.Contains(t, output,
+		"execloop_task_phase_duration_seconds_bucket{task=\"vm\\\"1\",phase=\"PerformAction\",result=\"error\",le=\"1\"} 1\n")
+	require.Contains(t, output, "execloop_task_errors_tolerated_total{task=\"vm\\\"1\"} 1\n")
+	require.Contains(t, output, "execloop_runs_total{outcome=\"converged\"} 1\n")
+	require.Contains(t, output, "execloop_runs_total{outcome=\"fatal\"} 1\n")
+	require.Contains(t, output, "execloop_fatal_exits_total 1\n")
+	require.Contains(t, output, "execloop_converged_total 0\n")
+}
+
+func TestMetricsOutcomes(t *testing.T) {
+	m := New()
+	opts := execloop.DefaultOptions().WithLogger(nil).WithSleepBetweenRuns(time.Millisecond).WithObserver(m).
+		WithErrorsToTolerate(0)
+	exec := executor.New(&opts)
+	err := exec.Run(&plan{tasks: []executor.Task{&task{name: "vm0", err: errors.New("boom")}}})
+	require.True(t, executor.IsFatal(err))
+
+	var output strings.Builder
+	_, err = m.WriteTo(&output)
+	require.Nil(t, err)
+	require.Contains(t, output.String(), "execloop_runs_total{outcome=\"budget-exhausted\"} 1\n")
+	require.Contains(t, output.String(), "execloop_fatal_exits_total 0\n")
+}
@@ -0,0 +1,69 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+import (
+	"fmt"
+	"time"
+)
+
+// Observer is notified about the progress of the executor. Observers are
+// called from the goroutines executing the tasks, so they should be safe
+// for concurrent use when tasks run in parallel. A panicking observer is
+// logged and ignored.
+type Observer interface {
+	OnIterationStart(iteration int)
+	OnPlanCreated(iteration int, tasks []string, duration time.Duration)
+	OnTaskPhaseStart(task string, phase Phase)
+	OnTaskPhaseEnd(task string, phase Phase, err error, duration time.Duration)
+	OnChildrenScheduled(task string, children []string)
+	OnErrorTolerated(task string, err error)
+	OnRunFinished(err error, duration time.Duration)
+	// OnConverged is called by Executor.Reconcile every time the plan
+	// reaches its final state
+	OnConverged(iteration int)
+}
+
+// NoopObserver implements every callback of Observer doing nothing. Embed
+// it to implement only the callbacks you are interested in.
+type NoopObserver struct{}
+
+func (NoopObserver) OnIterationStart(iteration int)                                             {}
+func (NoopObserver) OnPlanCreated(iteration int, tasks []string, duration time.Duration)        {}
+func (NoopObserver) OnTaskPhaseStart(task string, phase Phase)                                  {}
+func (NoopObserver) OnTaskPhaseEnd(task string, phase Phase, err error, duration time.Duration) {}
+func (NoopObserver) OnChildrenScheduled(task string, children []string)                         {}
+func (NoopObserver) OnErrorTolerated(task string, err error)                                    {}
+func (NoopObserver) OnRunFinished(err error, duration time.Duration)                            {}
+func (NoopObserver) OnConverged(iteration int)                                                  {}
+
+// Notify invokes fn for every registered observer recovering from panics.
+func (o *Options) Notify(fn func(Observer)) {
+	for _, observer := range o.Observers {
+		o.notify(observer, fn)
+	}
+}
+
+func (o *Options) notify(observer Observer, fn func(Observer)) {
+	defer func() {
+		if r := recover(); r != nil {
+			o.Error("Observer panicked", F("observer", fmt.Sprintf("%T", observer)), F("panic", r))
+		}
+	}()
+	fn(observer)
+}
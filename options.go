@@ -21,17 +21,56 @@ import "time"
 
 type Options struct {
 	Logger           Logger
+	StructuredLogger StructuredLogger
+	LogLevel         Level
 	SleepBetweenRuns time.Duration
 	ErrorsToTolerate int
-	ExecutionTimeout time.Duration
+	ErrorBudget      ErrorBudget
+	// ErrorBudgetWindow is the sliding window of the ErrorsInWindow budget,
+	// DefaultErrorBudgetWindow when zero
+	ErrorBudgetWindow time.Duration
+	ExecutionTimeout  time.Duration
+	MaxConcurrency    int
+	RetryPolicy       RetryPolicy
+	IntervalStrategy  IntervalStrategy
+	Observers         []Observer
+	Tracer            Tracer
+	Checkpointer      Checkpointer
+	RollbackOnFatal   bool
+	// MaxIdenticalIterations stops a plan returning the same tasks after
+	// that many consecutive iterations in which all of them succeeded
+	MaxIdenticalIterations int
+	MaxIterations          int
+	MaxTaskAttempts        int
+	MaxTotalTaskExecutions int
+	TaskTimeout            time.Duration
+	PreTimeout             time.Duration
+	ActionTimeout          time.Duration
+	PostTimeout            time.Duration
+	TimeoutsAreFatal       bool
+	PanicsAreFatal         bool
+	// WaitForTrigger waits for Executor.Trigger between iterations instead
+	// of polling
+	WaitForTrigger bool
+	// SteadyStateInterval is the time Executor.Reconcile waits before
+	// creating the plan again once it has converged
+	SteadyStateInterval time.Duration
+	// QueueWorkers is the number of keys Executor.Drain processes in
+	// parallel
+	QueueWorkers int
 }
 
 func DefaultOptions() Options {
 	return Options{
-		Logger:           defaultLog,
-		SleepBetweenRuns: time.Second,
-		ErrorsToTolerate: 5,
-		ExecutionTimeout: 20 * time.Minute,
+		Logger:            defaultLog,
+		LogLevel:          LevelInfo,
+		SleepBetweenRuns:  time.Second,
+		ErrorsToTolerate:  5,
+		ErrorBudget:       ErrorsPerRun,
+		ErrorBudgetWindow: DefaultErrorBudgetWindow,
+		ExecutionTimeout:  20 * time.Minute,
+		MaxConcurrency:    1,
+		QueueWorkers:      1,
 	}
 }
 
@@ -40,6 +79,16 @@ func (o Options) WithLogger(logger Logger) Options {
 	return o
 }
 
+func (o Options) WithStructuredLogger(logger StructuredLogger) Options {
+	o.StructuredLogger = logger
+	return o
+}
+
+func (o Options) WithLogLevel(level Level) Options {
+	o.LogLevel = level
+	return o
+}
+
 func (o Options) WithSleepBetweenRuns(sleep time.Duration) Options {
 	o.SleepBetweenRuns = sleep
 	return o
@@ -50,7 +99,126 @@ func (o Options) WithErrorsToTolerate(numOfErrors int) Options {
 	return o
 }
 
+func (o Options) WithErrorBudget(budget ErrorBudget) Options {
+	o.ErrorBudget = budget
+	return o
+}
+
+func (o Options) WithErrorBudgetWindow(window time.Duration) Options {
+	o.ErrorBudgetWindow = window
+	return o
+}
+
 func (o Options) WithExecutionTimeout(timeout time.Duration) Options {
 	o.ExecutionTimeout = timeout
 	return o
 }
+
+func (o Options) WithMaxConcurrency(maxConcurrency int) Options {
+	o.MaxConcurrency = maxConcurrency
+	return o
+}
+
+func (o Options) WithRetryPolicy(policy RetryPolicy) Options {
+	o.RetryPolicy = policy
+	return o
+}
+
+func (o Options) WithIntervalStrategy(strategy IntervalStrategy) Options {
+	o.IntervalStrategy = strategy
+	return o
+}
+
+func (o Options) WithObserver(observer Observer) Options {
+	o.Observers = append(append([]Observer(nil), o.Observers...), observer)
+	return o
+}
+
+func (o Options) WithTracer(tracer Tracer) Options {
+	o.Tracer = tracer
+	return o
+}
+
+func (o Options) WithCheckpointer(checkpointer Checkpointer) Options {
+	o.Checkpointer = checkpointer
+	return o
+}
+
+func (o Options) WithRollbackOnFatal(rollback bool) Options {
+	o.RollbackOnFatal = rollback
+	return o
+}
+
+func (o Options) WithMaxIdenticalIterations(iterations int) Options {
+	o.MaxIdenticalIterations = iterations
+	return o
+}
+
+func (o Options) WithMaxIterations(iterations int) Options {
+	o.MaxIterations = iterations
+	return o
+}
+
+func (o Options) WithMaxTaskAttempts(attempts int) Options {
+	o.MaxTaskAttempts = attempts
+	return o
+}
+
+func (o Options) WithMaxTotalTaskExecutions(executions int) Options {
+	o.MaxTotalTaskExecutions = executions
+	return o
+}
+
+func (o Options) WithTaskTimeout(timeout time.Duration) Options {
+	o.TaskTimeout = timeout
+	return o
+}
+
+func (o Options) WithPreTimeout(timeout time.Duration) Options {
+	o.PreTimeout = timeout
+	return o
+}
+
+func (o Options) WithActionTimeout(timeout time.Duration) Options {
+	o.ActionTimeout = timeout
+	return o
+}
+
+func (o Options) WithPostTimeout(timeout time.Duration) Options {
+	o.PostTimeout = timeout
+	return o
+}
+
+func (o Options) WithTimeoutsAreFatal(fatal bool) Options {
+	o.TimeoutsAreFatal = fatal
+	return o
+}
+
+func (o Options) WithPanicsAreFatal(fatal bool) Options {
+	o.PanicsAreFatal = fatal
+	return o
+}
+
+func (o Options) WithWaitForTrigger(wait bool) Options {
+	o.WaitForTrigger = wait
+	return o
+}
+
+func (o Options) WithSteadyStateInterval(interval time.Duration) Options {
+	o.SteadyStateInterval = interval
+	return o
+}
+
+func (o Options) WithQueueWorkers(workers int) Options {
+	o.QueueWorkers = workers
+	return o
+}
+
+// NextInterval returns the time to wait before the next iteration, falling
+// back to SleepBetweenRuns when no IntervalStrategy is set.
+func (o *Options) NextInterval(previous time.Duration, clean, progressed bool) time.Duration {
+	if o.IntervalStrategy == nil {
+		return o.SleepBetweenRuns
+	}
+	return o.IntervalStrategy.Next(previous, clean, progressed)
+}
@@ -0,0 +1,31 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package execloop
+
+// Phase is a step of the execution of a task.
+type Phase string
+
+const (
+	PhasePre           Phase = "Pre"
+	PhasePerformAction Phase = "PerformAction"
+	PhasePost          Phase = "Post"
+	// PhaseChildren is the execution of the children returned by PerformAction
+	PhaseChildren Phase = "Children"
+	// PhaseCompensate is the rollback of a task after a fatal error
+	PhaseCompensate Phase = "Compensate"
+)
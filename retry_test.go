@@ -0,0 +1,47 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package execloop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     time.Second,
+		Multiplier:   2,
+	}
+	require.Equal(t, 5, policy.Attempts())
+	require.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	require.Equal(t, 200*time.Millisecond, policy.Delay(2))
+	require.Equal(t, 400*time.Millisecond, policy.Delay(3))
+	require.Equal(t, time.Second, policy.Delay(5))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(2)
+		require.True(t, delay >= 100*time.Millisecond && delay <= 300*time.Millisecond)
+	}
+
+	require.Equal(t, 1, RetryPolicy{}.Attempts())
+	require.Equal(t, time.Duration(0), RetryPolicy{}.Delay(1))
+}
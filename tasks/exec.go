@@ -0,0 +1,233 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package tasks provides Task implementations for common operations.
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+)
+
+// Command is a command run by an ExecTask. Env is appended to the
+// environment of the current process.
+type Command struct {
+	Path  string
+	Args  []string
+	Env   []string
+	Dir   string
+	Stdin string
+	// Timeout overrides the timeout of the phase running the command
+	Timeout time.Duration
+}
+
+func (c *Command) String() string {
+	return strings.Join(append([]string{c.Path}, c.Args...), " ")
+}
+
+// CommandError is returned when a command exits with a non-zero code.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("Command %s exited with code %d", e.Command, e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// ExecTask runs Command in PerformAction and the optional PreCommand and
+// PostCommand in Pre and Post. The stdout and stderr of every command are
+// recorded in the RunReport. A command exiting with one of
+// RetryableExitCodes fails with a Retryable error, with one of
+// FatalExitCodes with a FatalError and with any other non-zero code with an
+// error counting towards the error budget.
+//
+// ExecTask is an executor.ContextTask so that commands are killed when their
+// phase times out or the run is cancelled. Use Task to return it from a
+// Plan.
+type ExecTask struct {
+	TaskName    string
+	Description string
+	Command     Command
+	PreCommand  *Command
+	PostCommand *Command
+	// Dependencies are the names of the tasks which must succeed first
+	Dependencies       []string
+	RetryableExitCodes []int
+	FatalExitCodes     []int
+	Retry              execloop.RetryPolicy
+	Timeout            time.Duration
+
+	mu     sync.Mutex
+	output map[execloop.Phase][2]string
+}
+
+func (t *ExecTask) Task() executor.Task {
+	return executor.FromContextTask(t)
+}
+
+func (t *ExecTask) Pre(ctx context.Context) error {
+	return t.run(ctx, execloop.PhasePre, t.PreCommand)
+}
+
+func (t *ExecTask) PerformAction(ctx context.Context) ([]executor.Task, error) {
+	return nil, t.run(ctx, execloop.PhasePerformAction, &t.Command)
+}
+
+func (t *ExecTask) Post(ctx context.Context) error {
+	return t.run(ctx, execloop.PhasePost, t.PostCommand)
+}
+
+func (t *ExecTask) Name() string {
+	return t.TaskName
+}
+
+func (t *ExecTask) DependsOn() []string {
+	return t.Dependencies
+}
+
+func (t *ExecTask) RetryPolicy() execloop.RetryPolicy {
+	return t.Retry
+}
+
+func (t *ExecTask) TaskTimeout() time.Duration {
+	return t.Timeout
+}
+
+func (t *ExecTask) PhaseTimeout(phase execloop.Phase) time.Duration {
+	if command := t.command(phase); command != nil {
+		return command.Timeout
+	}
+	return 0
+}
+
+func (t *ExecTask) Output(phase execloop.Phase) (string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	output := t.output[phase]
+	return output[0], output[1]
+}
+
+func (t *ExecTask) Preview() (string, []executor.Task) {
+	if t.Description != "" {
+		return t.Description, nil
+	}
+	return t.Command.String(), nil
+}
+
+func (t *ExecTask) command(phase execloop.Phase) *Command {
+	switch phase {
+	case execloop.PhasePre:
+		return t.PreCommand
+	case execloop.PhasePerformAction:
+		return &t.Command
+	case execloop.PhasePost:
+		return t.PostCommand
+	}
+	return nil
+}
+
+func (t *ExecTask) run(ctx context.Context, phase execloop.Phase, command *Command) error {
+	if command == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cmd := exec.Command(command.Path, command.Args...)
+	setProcessGroup(cmd)
+	cmd.Dir = command.Dir
+	if len(command.Env) > 0 {
+		cmd.Env = append(os.Environ(), command.Env...)
+	}
+	if command.Stdin != "" {
+		cmd.Stdin = strings.NewReader(command.Stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := runCommand(ctx, cmd)
+
+	t.mu.Lock()
+	if t.output == nil {
+		t.output = make(map[execloop.Phase][2]string)
+	}
+	t.output[phase] = [2]string{stdout.String(), stderr.String()}
+	t.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		return fmt.Errorf("Could not run %s: %w", command, err)
+	}
+	cerr := &CommandError{Command: command.String(), ExitCode: exitError.ExitCode(), Stderr: stderr.String()}
+	switch {
+	case contains(t.FatalExitCodes, cerr.ExitCode):
+		return executor.Fatal(cerr)
+	case contains(t.RetryableExitCodes, cerr.ExitCode):
+		return executor.Retryable(cerr)
+	}
+	return cerr
+}
+
+// runCommand runs the command, killing its process group when ctx is done.
+// Unlike exec.CommandContext, this also kills the processes started by the
+// command which would otherwise keep its output open and make Wait block.
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	waited := make(chan struct{})
+	defer close(waited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-waited:
+		}
+	}()
+	return cmd.Wait()
+}
+
+func contains(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package tasks
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/kouzant/execloop/executor"
+	"github.com/stretchr/testify/require"
+)
+
+type plan struct {
+	tasks []executor.Task
+	done  bool
+}
+
+func (p *plan) Create() ([]executor.Task, error) {
+	if p.done {
+		return nil, nil
+	}
+	p.done = true
+	return p.tasks, nil
+}
+
+func sh(script string) Command {
+	return Command{Path: "sh", Args: []string{"-c", script}}
+}
+
+func run(t *testing.T, tasks ...*ExecTask) (*executor.RunReport, error) {
+	p := &plan{}
+	for _, task := range tasks {
+		p.tasks = append(p.tasks, task.Task())
+	}
+	opts := execloop.DefaultOptions().WithLogger(nil).WithSleepBetweenRuns(0)
+	return executor.New(&opts).RunWithResult(context.Background(), p)
+}
+
+func TestExecTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execloop")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	pre := sh("echo pre")
+	post := sh("echo post >&2")
+	command := sh("echo $GREETING; pwd; cat")
+	command.Env = []string{"GREETING=hello"}
+	command.Dir = dir
+	command.Stdin = "from stdin"
+	report, err := run(t, &ExecTask{TaskName: "greet", Command: command, PreCommand: &pre, PostCommand: &post})
+	require.Nil(t, err)
+
+	phases := report.Iterations[0].Tasks[0].Phases
+	require.Len(t, phases, 3)
+	require.Equal(t, "pre\n", phases[0].Stdout)
+	dir, err = filepath.EvalSymlinks(dir)
+	require.Nil(t, err)
+	require.Equal(t, "hello\n"+dir+"\nfrom stdin", phases[1].Stdout)
+	require.Equal(t, "", phases[2].Stdout)
+	require.Equal(t, "post\n", phases[2].Stderr)
+}
+
+func TestExecTaskExitCodes(t *testing.T) {
+	retryable := &ExecTask{TaskName: "retryable", Command: sh("echo busy >&2; exit 75"), RetryableExitCodes: []int{75}}
+	failing := &ExecTask{TaskName: "failing", Command: sh("exit 1")}
+	report, err := run(t, retryable, failing)
+	require.Nil(t, err)
+	require.Equal(t, 2, report.Errors)
+	require.Equal(t, 1, report.BudgetErrors)
+	retryableErr := report.Iterations[0].Tasks[0].Phases[1].Err
+	require.True(t, executor.IsRetryable(retryableErr))
+	var commandError *CommandError
+	require.True(t, errors.As(retryableErr, &commandError))
+	require.Equal(t, &CommandError{Command: "sh -c echo busy >&2; exit 75", ExitCode: 75, Stderr: "busy\n"},
+		commandError)
+	require.Equal(t, "Command sh -c echo busy >&2; exit 75 exited with code 75: busy", commandError.Error())
+
+	_, err = run(t, &ExecTask{TaskName: "fatal", Command: sh("exit 3"), FatalExitCodes: []int{3}})
+	require.True(t, executor.IsFatal(err))
+	require.True(t, errors.As(err, &commandError))
+	require.Equal(t, 3, commandError.ExitCode)
+}
+
+func TestExecTaskTimeout(t *testing.T) {
+	command := sh("sleep 10")
+	command.Timeout = 50 * time.Millisecond
+	started := time.Now()
+	report, err := run(t, &ExecTask{TaskName: "sleepy", Command: command, Retry: execloop.RetryPolicy{MaxAttempts: 2}})
+	require.Nil(t, err)
+	require.True(t, time.Since(started) < 5*time.Second)
+	var timeoutError *executor.TimeoutError
+	require.True(t, errors.As(report.Iterations[0].Tasks[0].Phases[1].Err, &timeoutError))
+}
+
+func TestExecTaskKillsProcessGroup(t *testing.T) {
+	// the shell waits for sleep, which keeps the output of the command open
+	task := &ExecTask{TaskName: "sleepy", Command: sh("sleep 10; echo done")}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := task.PerformAction(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.True(t, time.Since(started) < 5*time.Second)
+}
+
+func TestExecTaskPreview(t *testing.T) {
+	p := &plan{tasks: []executor.Task{(&ExecTask{TaskName: "a", Command: sh("true")}).Task(),
+		(&ExecTask{TaskName: "b", Description: "Run b", Command: sh("true"), Dependencies: []string{"a"}}).Task()}}
+	opts := execloop.DefaultOptions().WithLogger(nil)
+	preview, err := executor.New(&opts).DryRun(p)
+	require.Nil(t, err)
+	require.Equal(t, "├── a: sh -c true\n└── b (after a): Run b\n", preview.String())
+}
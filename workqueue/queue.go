@@ -0,0 +1,210 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package workqueue provides a queue of keys modeled on the work queues of
+// controllers: a key is queued at most once, it is never handed to two
+// workers at the same time and failing keys are requeued with exponential
+// backoff.
+package workqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kouzant/execloop"
+)
+
+// DefaultBackoff is the backoff of the queues created with New.
+var DefaultBackoff = execloop.RetryPolicy{
+	InitialDelay: 5 * time.Millisecond,
+	MaxDelay:     5 * time.Minute,
+	Multiplier:   2,
+}
+
+type Queue struct {
+	mu      sync.Mutex
+	backoff execloop.RetryPolicy
+	// queue holds the keys in the order they were added, queued the same
+	// keys for deduplication
+	queue  []string
+	queued map[string]bool
+	// dirty keys were added while being processed and are queued again
+	// when Done is called
+	processing map[string]bool
+	dirty      map[string]bool
+	delayed    map[string]time.Time
+	failures   map[string]int
+	changed    chan struct{}
+	shutdown   bool
+}
+
+func New() *Queue {
+	return NewWithBackoff(DefaultBackoff)
+}
+
+// NewWithBackoff creates a Queue whose AddRateLimited delays a key by
+// backoff.Delay of the number of times it has been requeued.
+func NewWithBackoff(backoff execloop.RetryPolicy) *Queue {
+	return &Queue{
+		backoff:    backoff,
+		queued:     make(map[string]bool),
+		processing: make(map[string]bool),
+		dirty:      make(map[string]bool),
+		delayed:    make(map[string]time.Time),
+		failures:   make(map[string]int),
+		changed:    make(chan struct{}),
+	}
+}
+
+// Add queues a key unless it is queued already. A key being processed is
+// queued again once it is Done.
+func (q *Queue) Add(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.add(key)
+}
+
+func (q *Queue) add(key string) {
+	if q.shutdown || q.queued[key] {
+		return
+	}
+	if q.processing[key] {
+		q.dirty[key] = true
+		return
+	}
+	q.queued[key] = true
+	q.queue = append(q.queue, key)
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// AddAfter queues a key once the delay has passed. Only the earliest of
+// several delayed adds of the same key is kept.
+func (q *Queue) AddAfter(key string, delay time.Duration) {
+	if delay <= 0 {
+		q.Add(key)
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.shutdown {
+		return
+	}
+	at := time.Now().Add(delay)
+	if pending, ok := q.delayed[key]; ok && !at.Before(pending) {
+		return
+	}
+	q.delayed[key] = at
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.delayed[key] != at {
+			return
+		}
+		delete(q.delayed, key)
+		q.add(key)
+	})
+}
+
+// AddRateLimited queues a key after the backoff of the times it has been
+// requeued since it was last forgotten.
+func (q *Queue) AddRateLimited(key string) {
+	q.mu.Lock()
+	q.failures[key]++
+	delay := q.backoff.Delay(q.failures[key])
+	q.mu.Unlock()
+	q.AddAfter(key, delay)
+}
+
+// Forget resets the backoff of a key, typically after it was processed
+// successfully.
+func (q *Queue) Forget(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.failures, key)
+}
+
+func (q *Queue) NumRequeues(key string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.failures[key]
+}
+
+// Get blocks until a key is available and marks it as being processed. The
+// caller must call Done with the key once finished. It returns false when
+// ctx is done or the queue is shut down.
+func (q *Queue) Get(ctx context.Context) (string, bool) {
+	for {
+		q.mu.Lock()
+		if q.shutdown {
+			q.mu.Unlock()
+			return "", false
+		}
+		if len(q.queue) > 0 {
+			key := q.queue[0]
+			q.queue = q.queue[1:]
+			delete(q.queued, key)
+			q.processing[key] = true
+			q.mu.Unlock()
+			return key, true
+		}
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", false
+		}
+	}
+}
+
+// Done marks a key as processed, queueing it again if it was added in the
+// meantime.
+func (q *Queue) Done(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.processing, key)
+	if q.dirty[key] {
+		delete(q.dirty, key)
+		q.add(key)
+	}
+}
+
+// Len returns the number of keys waiting to be processed.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queue)
+}
+
+// ShutDown stops accepting keys and makes Get return false.
+func (q *Queue) ShutDown() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.shutdown {
+		return
+	}
+	q.shutdown = true
+	close(q.changed)
+}
+
+func (q *Queue) ShuttingDown() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.shutdown
+}
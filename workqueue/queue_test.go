@@ -0,0 +1,110 @@
+/*
+This file is part of execloop.
+
+execloop is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+execloop is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with execloop.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package workqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kouzant/execloop"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplication(t *testing.T) {
+	q := New()
+	q.Add("a")
+	q.Add("b")
+	q.Add("a")
+	require.Equal(t, 2, q.Len())
+
+	key, ok := q.Get(context.Background())
+	require.True(t, ok)
+	require.Equal(t, "a", key)
+	// a is being processed so it is queued again only once it is done
+	q.Add("a")
+	q.Add("a")
+	require.Equal(t, 1, q.Len())
+	key, _ = q.Get(context.Background())
+	require.Equal(t, "b", key)
+	require.Equal(t, 0, q.Len())
+	q.Done("a")
+	require.Equal(t, 1, q.Len())
+	key, _ = q.Get(context.Background())
+	require.Equal(t, "a", key)
+	q.Done("a")
+	q.Done("b")
+	require.Equal(t, 0, q.Len())
+}
+
+func TestGetBlocks(t *testing.T) {
+	q := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, ok := q.Get(ctx)
+	require.False(t, ok)
+
+	keys := make(chan string)
+	go func() {
+		key, _ := q.Get(context.Background())
+		keys <- key
+	}()
+	q.Add("a")
+	require.Equal(t, "a", <-keys)
+
+	go func() {
+		_, ok := q.Get(context.Background())
+		require.False(t, ok)
+		close(keys)
+	}()
+	q.ShutDown()
+	<-keys
+	require.True(t, q.ShuttingDown())
+	q.Add("b")
+	require.Equal(t, 0, q.Len())
+}
+
+func TestAddRateLimited(t *testing.T) {
+	q := NewWithBackoff(execloop.RetryPolicy{InitialDelay: 20 * time.Millisecond, Multiplier: 2})
+	started := time.Now()
+	q.AddRateLimited("a")
+	require.Equal(t, 0, q.Len())
+	key, _ := q.Get(context.Background())
+	require.Equal(t, "a", key)
+	require.True(t, time.Since(started) >= 20*time.Millisecond)
+	q.Done("a")
+
+	started = time.Now()
+	q.AddRateLimited("a")
+	q.Get(context.Background())
+	require.True(t, time.Since(started) >= 40*time.Millisecond)
+	require.Equal(t, 2, q.NumRequeues("a"))
+	q.Forget("a")
+	require.Equal(t, 0, q.NumRequeues("a"))
+}
+
+func TestAddAfterKeepsEarliest(t *testing.T) {
+	q := New()
+	q.AddAfter("a", time.Hour)
+	q.AddAfter("a", 10*time.Millisecond)
+	q.AddAfter("a", time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	key, ok := q.Get(ctx)
+	require.True(t, ok)
+	require.Equal(t, "a", key)
+}